@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"restaurant-management-backend/helper"
+	"restaurant-management-backend/models"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// OrderItemAdjustmentRequest voids or comps an order item. Above the approval
+// threshold a staff member needs a manager to approve.
+type OrderItemAdjustmentRequest struct {
+	Reason_code      *string             `json:"reason_code" validate:"required"`
+	Note             *string             `json:"note" validate:"omitempty,max=200"`
+	Manager_approval *ManagerCredentials `json:"manager_approval"`
+}
+
+// ManagerCredentials are entered by a manager at a staff member's terminal
+// to approve a single action, without handing over their session.
+type ManagerCredentials struct {
+	Email    *string `json:"email" validate:"required,email"`
+	Password *string `json:"password" validate:"required"`
+}
+
+func VoidOrderItem() gin.HandlerFunc {
+	return adjustOrderItem("VOID")
+}
+
+func CompOrderItem() gin.HandlerFunc {
+	return adjustOrderItem("COMP")
+}
+
+// adjustOrderItem voids an order item that has not been prepared yet or
+// comps one that has.
+func adjustOrderItem(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var request OrderItemAdjustmentRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if kind == "VOID" && !models.IsVoidReason(*request.Reason_code) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reason_code must be one of the void reasons", "reasons": models.VoidReasons})
+			return
+		}
+		if kind == "COMP" && !models.IsCompReason(*request.Reason_code) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reason_code must be one of the comp reasons", "reasons": models.CompReasons})
+			return
+		}
+
+		// retrieve the order item, its order must still be open
+		orderItemId := c.Param("order_item_id")
+		var orderItem models.OrderItem
+		if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": errOrderItemNotFound.Error()})
+			return
+		}
+		if err := orderOpenForPriceChanges(ctx, orderItem.Order_id); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+		if orderItem.Adjustment != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("order item was already %s", adjustedName(orderItem.Adjustment.Kind))})
+			return
+		}
+
+		// voids happen before preparation, comps after
+		status := orderItemStatus(orderItem)
+		if kind == "VOID" && !slices.Contains([]string{"PENDING", "HELD", "FIRED"}, status) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("a %s order item cannot be voided, comp it instead", status)})
+			return
+		}
+		if kind == "COMP" && !slices.Contains([]string{"PREPARING", "READY", "SERVED"}, status) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("a %s order item cannot be comped, void it instead", status)})
+			return
+		}
+
+		// what the line would have cost, approved by a manager above the threshold
+		adjustment := models.OrderItemAdjustment{
+			Kind:         kind,
+			Reason_code:  *request.Reason_code,
+			Note:         request.Note,
+			Amount:       orderItemNet(orderItem),
+			Requested_by: c.GetString("uid"),
+		}
+		adjustment.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if adjustment.Amount > adjustmentApprovalThreshold() {
+			approvedBy, err := managerApproval(ctx, c.GetString("uid"), request.Manager_approval)
+			if err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			adjustment.Approved_by = &approvedBy
+		}
+
+		// record it, once
+		result, err := orderItemCollection.UpdateOne(ctx,
+			bson.M{"order_item_id": orderItemId, "adjustment": nil},
+			bson.M{"$set": bson.M{"adjustment": adjustment, "updated_at": adjustment.Created_at}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order item was not adjusted"})
+			return
+		}
+		if result.ModifiedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "order item was already adjusted"})
+			return
+		}
+
+		// a voided item leaves the kitchen
+		if kind == "VOID" {
+			reason := adjustment.Reason_code
+			if _, err := transitionOrderItem(ctx, orderItemId, "VOIDED", adjustment.Requested_by, &reason); err != nil {
+				orderItemCollection.UpdateOne(ctx, bson.M{"order_item_id": orderItemId}, bson.M{"$set": bson.M{"adjustment": nil}})
+				orderChangeError(c, err)
+				return
+			}
+			if err := rollUpOrderStatus(ctx, orderItem.Order_id, adjustment.Requested_by); err != nil {
+				log.Println("order status was not rolled up:", orderItem.Order_id, err)
+			}
+			// it was not made, so the stock taken for it is still there
+			if err := RestoreStock(ctx, []models.OrderItem{orderItem}, adjustment.Requested_by); err != nil {
+				log.Println("stock was not restored for the voided order item:", orderItemId, err)
+			}
+		}
+		publishOrderItem(ctx, "order_item.updated", orderItemId)
+
+		// response
+		c.JSON(http.StatusOK, adjustment)
+	}
+}
+
+func GetAdjustmentReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// filter by period and kind
+		from, to, err := reportPeriod(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		match := bson.M{"adjustment.created_at": bson.M{"$gte": from, "$lt": to}}
+		if kind := c.Query("kind"); kind != "" {
+			match["adjustment.kind"] = kind
+		}
+
+		// sum by reason and by staff member, and list the items
+		sumBy := func(id bson.D) mongo.Pipeline {
+			return mongo.Pipeline{
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: id},
+					{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+					{Key: "amount", Value: bson.D{{Key: "$sum", Value: "$adjustment.amount"}}},
+				}}},
+				bson.D{{Key: "$addFields", Value: bson.D{{Key: "amount", Value: bson.D{{Key: "$round", Value: bson.A{"$amount", 2}}}}}}},
+				bson.D{{Key: "$sort", Value: bson.D{{Key: "amount", Value: -1}, {Key: "_id", Value: 1}}}},
+			}
+		}
+		result, err := orderItemCollection.Aggregate(ctx, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: match}},
+			bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "food"}, {Key: "localField", Value: "food_id"}, {Key: "foreignField", Value: "food_id"}, {Key: "as", Value: "food"}}}},
+			bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "combo"}, {Key: "localField", Value: "combo_id"}, {Key: "foreignField", Value: "combo_id"}, {Key: "as", Value: "combo"}}}},
+			bson.D{{Key: "$facet", Value: bson.D{
+				{Key: "by_reason", Value: sumBy(bson.D{{Key: "kind", Value: "$adjustment.kind"}, {Key: "reason_code", Value: "$adjustment.reason_code"}})},
+				{Key: "by_staff", Value: sumBy(bson.D{{Key: "kind", Value: "$adjustment.kind"}, {Key: "requested_by", Value: "$adjustment.requested_by"}})},
+				{Key: "items", Value: mongo.Pipeline{
+					bson.D{{Key: "$sort", Value: bson.D{{Key: "adjustment.created_at", Value: 1}}}},
+					bson.D{{Key: "$project", Value: bson.D{
+						{Key: "_id", Value: 0},
+						{Key: "order_item_id", Value: 1},
+						{Key: "order_id", Value: 1},
+						{Key: "food_name", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+							bson.D{{Key: "$first", Value: "$food.name"}},
+							bson.D{{Key: "$first", Value: "$combo.name"}},
+						}}}},
+						{Key: "adjustment", Value: 1},
+					}}},
+				}},
+			}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the void and comp report"})
+			return
+		}
+
+		// decode
+		var report []bson.M
+		if err = result.All(ctx, &report); err != nil || len(report) == 0 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the void and comp report"})
+			return
+		}
+
+		// response
+		report[0]["from"] = from
+		report[0]["to"] = to
+		c.JSON(http.StatusOK, report[0])
+	}
+}
+
+// orderItemNet is what an order item is charged before order discounts and tax.
+func orderItemNet(orderItem models.OrderItem) float64 {
+	var gross int64
+	if orderItem.Unit_price != nil {
+		gross = helper.ToCents(*orderItem.Unit_price)
+	}
+	return helper.FromCents(gross - helper.DiscountCents(orderItem.Discount, gross))
+}
+
+// adjustmentApprovalThreshold is the order item value above which voids and
+// comps need a manager's approval.
+func adjustmentApprovalThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("ADJUSTMENT_APPROVAL_THRESHOLD"), 64)
+	if err != nil || threshold < 0 {
+		threshold = 20
+	}
+	return threshold
+}
+
+// managerApproval returns the manager approving: the signed in user when
+// they are a manager, or else the manager whose credentials were entered.
+func managerApproval(ctx context.Context, uid string, approval *ManagerCredentials) (string, error) {
+	if isManager(ctx, uid) {
+		return uid, nil
+	}
+	if approval == nil {
+		return "", fmt.Errorf("a manager must approve this with their manager_approval email and password")
+	}
+	var manager models.User
+	if err := userCollection.FindOne(ctx, bson.M{"email": approval.Email, "role": "MANAGER"}).Decode(&manager); err != nil {
+		return "", fmt.Errorf("manager_approval is not a manager's email and password")
+	}
+	if ok, _ := VerifyPassword(*approval.Password, *manager.Password); !ok {
+		return "", fmt.Errorf("manager_approval is not a manager's email and password")
+	}
+	return manager.User_id, nil
+}
+
+func adjustedName(kind string) string {
+	if kind == "COMP" {
+		return "comped"
+	}
+	return "voided"
+}
@@ -0,0 +1,266 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var comboCollection *mongo.Collection = database.OpenCollection(database.Client, "combo")
+
+func GetCombos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve, optionally by menu
+		filter := bson.M{}
+		if menuId := c.Query("menu_id"); menuId != "" {
+			filter["menu_id"] = menuId
+		}
+		result, err := comboCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing combos"})
+			return
+		}
+
+		// decode
+		allCombos := []bson.M{}
+		if err = result.All(ctx, &allCombos); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing combos"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allCombos)
+	}
+}
+
+func GetCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve and decode
+		comboId := c.Param("combo_id")
+		var combo models.Combo
+		if err := comboCollection.FindOne(ctx, bson.M{"combo_id": comboId}).Decode(&combo); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "combo was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, combo)
+	}
+}
+
+func CreateCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var combo models.Combo
+		if err := c.BindJSON(&combo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(combo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateComboSlots(ctx, combo.Slots); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		combo.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		combo.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		combo.ID = primitive.NewObjectID()
+		combo.Combo_id = combo.ID.Hex()
+		var num = toFixed(*combo.Price, 2)
+		combo.Price = &num
+		if combo.Is_available == nil {
+			available := true
+			combo.Is_available = &available
+		}
+		if combo.Tax_category == nil {
+			category := defaultTaxCategory
+			combo.Tax_category = &category
+		}
+
+		// insert
+		result, err := comboCollection.InsertOne(ctx, combo)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "combo was not created"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind
+		var combo models.Combo
+		if err := c.BindJSON(&combo); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// prepare updated obj
+		var updateObj primitive.D
+		if combo.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: combo.Name})
+		}
+		if combo.Description != nil {
+			updateObj = append(updateObj, bson.E{Key: "description", Value: combo.Description})
+		}
+		if combo.Price != nil {
+			var num = toFixed(*combo.Price, 2)
+			updateObj = append(updateObj, bson.E{Key: "price", Value: num})
+		}
+		if combo.Slots != nil {
+			if err := validate.Var(combo.Slots, "min=1,dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := validateComboSlots(ctx, combo.Slots); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "slots", Value: combo.Slots})
+		}
+		if combo.Menu_id != nil {
+			updateObj = append(updateObj, bson.E{Key: "menu_id", Value: combo.Menu_id})
+		}
+		if combo.Is_available != nil {
+			updateObj = append(updateObj, bson.E{Key: "is_available", Value: combo.Is_available})
+		}
+		if combo.Tax_category != nil {
+			if err := validate.Var(*combo.Tax_category, "eq=FOOD|eq=ALCOHOL|eq=TAKEAWAY"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "tax_category must be one of FOOD, ALCOHOL or TAKEAWAY"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "tax_category", Value: combo.Tax_category})
+		}
+		combo.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: combo.Updated_at})
+
+		// update mongodb
+		comboId := c.Param("combo_id")
+		result, err := comboCollection.UpdateOne(
+			ctx,
+			bson.M{"combo_id": comboId},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+			},
+			options.Update(),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "combo update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "combo was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// validateComboSlots checks slot names are unique and every food a slot offers exists.
+func validateComboSlots(ctx context.Context, slots []models.ComboSlot) error {
+	names := map[string]bool{}
+	var foodIds []string
+	for _, slot := range slots {
+		if names[slot.Name] {
+			return fmt.Errorf("duplicate combo slot: %s", slot.Name)
+		}
+		names[slot.Name] = true
+		foodIds = append(foodIds, slot.Food_ids...)
+	}
+
+	found, err := foodCollection.Distinct(ctx, "food_id", bson.M{"food_id": bson.M{"$in": foodIds}})
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, id := range found {
+		if id, ok := id.(string); ok {
+			existing[id] = true
+		}
+	}
+	for _, id := range foodIds {
+		if !existing[id] {
+			return fmt.Errorf("food was not found: %s", id)
+		}
+	}
+	return nil
+}
+
+// resolveComboOrderItem checks the components of a combo order item fill
+// every slot of the combo with one of the slot's foods and prices the line
+// at the combo's bundle price.
+func resolveComboOrderItem(ctx context.Context, orderItem *models.OrderItem) error {
+	var combo models.Combo
+	if err := comboCollection.FindOne(ctx, bson.M{"combo_id": orderItem.Combo_id}).Decode(&combo); err != nil {
+		return fmt.Errorf("combo was not found: %s", *orderItem.Combo_id)
+	}
+	if combo.Is_available != nil && !*combo.Is_available {
+		return fmt.Errorf("combo is not available: %s", *orderItem.Combo_id)
+	}
+
+	chosen := map[string]string{}
+	for _, component := range orderItem.Components {
+		if _, ok := chosen[component.Slot]; ok {
+			return fmt.Errorf("combo slot chosen more than once: %s", component.Slot)
+		}
+		chosen[component.Slot] = component.Food_id
+	}
+
+	components := []models.OrderItemComponent{}
+	for _, slot := range combo.Slots {
+		foodId, ok := chosen[slot.Name]
+		if !ok {
+			return fmt.Errorf("combo slot was not chosen: %s", slot.Name)
+		}
+		if !slices.Contains(slot.Food_ids, foodId) {
+			return fmt.Errorf("food %s is not a choice for combo slot %s", foodId, slot.Name)
+		}
+		components = append(components, models.OrderItemComponent{Slot: slot.Name, Food_id: foodId})
+		delete(chosen, slot.Name)
+	}
+	for slot := range chosen {
+		return fmt.Errorf("combo has no slot: %s", slot)
+	}
+
+	orderItem.Food_id = nil
+	orderItem.Components = components
+	orderItem.Unit_price = combo.Price
+	orderItem.Base_price = combo.Price
+	orderItem.Pricing_rule = nil
+	orderItem.Tax_category = combo.Tax_category
+	return nil
+}
@@ -0,0 +1,487 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PromoCodeRedemption struct {
+	Code *string `json:"code" validate:"required"`
+}
+
+var promoCodeCollection *mongo.Collection = database.OpenCollection(database.Client, "promoCode")
+
+func GetPromoCodes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve
+		result, err := promoCodeCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing promo codes"})
+			return
+		}
+
+		// decode
+		allPromoCodes := []bson.M{}
+		if err = result.All(ctx, &allPromoCodes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing promo codes"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allPromoCodes)
+	}
+}
+
+func GetPromoCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve and decode
+		promoCodeId := c.Param("promo_code_id")
+		var promoCode models.PromoCode
+		if err := promoCodeCollection.FindOne(ctx, bson.M{"promo_code_id": promoCodeId}).Decode(&promoCode); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "promo code was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, promoCode)
+	}
+}
+
+func CreatePromoCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var promoCode models.PromoCode
+		if err := c.BindJSON(&promoCode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(promoCode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if *promoCode.Kind == "PERCENT" && *promoCode.Value > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a percentage discount must not exceed 100"})
+			return
+		}
+		if promoCode.Valid_from != nil && promoCode.Valid_until != nil && !promoCode.Valid_from.Before(*promoCode.Valid_until) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "valid_from must be before valid_until"})
+			return
+		}
+
+		// codes are unique regardless of case
+		code := strings.ToUpper(*promoCode.Code)
+		promoCode.Code = &code
+		count, err := promoCodeCollection.CountDocuments(ctx, bson.M{"code": code})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking for the promo code"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "this promo code already exists"})
+			return
+		}
+
+		promoCode.Used_count = 0
+		promoCode.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		promoCode.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		promoCode.ID = primitive.NewObjectID()
+		promoCode.Promo_code_id = promoCode.ID.Hex()
+		if promoCode.Is_active == nil {
+			active := true
+			promoCode.Is_active = &active
+		}
+
+		// insert
+		result, err := promoCodeCollection.InsertOne(ctx, promoCode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "promo code was not created"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdatePromoCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind
+		var promoCode models.PromoCode
+		if err := c.BindJSON(&promoCode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// prepare updated obj, the code and discount stay as redeemed
+		var updateObj primitive.D
+		if promoCode.Description != nil {
+			updateObj = append(updateObj, bson.E{Key: "description", Value: promoCode.Description})
+		}
+		if promoCode.Max_uses != nil {
+			if *promoCode.Max_uses < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "max_uses must be at least 1"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "max_uses", Value: promoCode.Max_uses})
+		}
+		if promoCode.Valid_from != nil {
+			updateObj = append(updateObj, bson.E{Key: "valid_from", Value: promoCode.Valid_from})
+		}
+		if promoCode.Valid_until != nil {
+			updateObj = append(updateObj, bson.E{Key: "valid_until", Value: promoCode.Valid_until})
+		}
+		if promoCode.Is_active != nil {
+			updateObj = append(updateObj, bson.E{Key: "is_active", Value: promoCode.Is_active})
+		}
+		promoCode.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: promoCode.Updated_at})
+
+		// update mongodb
+		promoCodeId := c.Param("promo_code_id")
+		result, err := promoCodeCollection.UpdateOne(
+			ctx,
+			bson.M{"promo_code_id": promoCodeId},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "promo code update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "promo code was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func AddOrderDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		discount, err := bindDiscount(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// paid and cancelled orders are not discounted
+		orderId := c.Param("order_id")
+		if err := orderOpenForPriceChanges(ctx, orderId); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+
+		// add to the order
+		result, err := orderCollection.UpdateOne(ctx,
+			bson.M{"order_id": orderId},
+			bson.M{"$push": bson.M{"discounts": discount}, "$set": bson.M{"updated_at": discount.Created_at}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order discount was not added"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order was not found"})
+			return
+		}
+
+		publishOrder(ctx, "order.updated", orderId)
+
+		// response
+		c.JSON(http.StatusOK, discount)
+	}
+}
+
+func RedeemPromoCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var redemption PromoCodeRedemption
+		if err := c.BindJSON(&redemption); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(redemption); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		code := strings.ToUpper(*redemption.Code)
+
+		// a code is redeemed once per order
+		orderId := c.Param("order_id")
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order was not found"})
+			return
+		}
+		if status := orderStatus(order); models.OrderIsClosed(status) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("a %s order cannot be changed", status)})
+			return
+		}
+		if err := orderHasNoPayments(ctx, orderId); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+		for _, discount := range order.Discounts {
+			if discount.Promo_code != nil && *discount.Promo_code == code {
+				c.JSON(http.StatusConflict, gin.H{"error": "this promo code was already redeemed on the order"})
+				return
+			}
+		}
+
+		// use the code if it is active, valid now and not used up
+		now := time.Now()
+		var promoCode models.PromoCode
+		err := promoCodeCollection.FindOneAndUpdate(ctx,
+			bson.M{
+				"code":      code,
+				"is_active": true,
+				"$and": bson.A{
+					bson.M{"$or": bson.A{bson.M{"valid_from": nil}, bson.M{"valid_from": bson.M{"$lte": now}}}},
+					bson.M{"$or": bson.A{bson.M{"valid_until": nil}, bson.M{"valid_until": bson.M{"$gt": now}}}},
+					bson.M{"$or": bson.A{bson.M{"max_uses": nil}, bson.M{"$expr": bson.M{"$lt": bson.A{"$used_count", "$max_uses"}}}}},
+				},
+			},
+			bson.M{"$inc": bson.M{"used_count": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&promoCode)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "this promo code is invalid, expired or used up"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while redeeming the promo code"})
+			return
+		}
+
+		// add its discount to the order
+		reason := "Promo code " + code
+		discount := models.Discount{
+			Discount_id:   primitive.NewObjectID().Hex(),
+			Kind:          promoCode.Kind,
+			Value:         promoCode.Value,
+			Reason:        &reason,
+			Promo_code:    &code,
+			Authorized_by: c.GetString("uid"),
+		}
+		discount.Created_at, _ = time.Parse(time.RFC3339, now.Format(time.RFC3339))
+		result, err := orderCollection.UpdateOne(ctx,
+			bson.M{"order_id": orderId, "discounts.promo_code": bson.M{"$ne": code}},
+			bson.M{"$push": bson.M{"discounts": discount}, "$set": bson.M{"updated_at": discount.Created_at}},
+		)
+		if err != nil || result.MatchedCount == 0 {
+			releasePromoCode(ctx, code)
+			c.JSON(http.StatusConflict, gin.H{"error": "the promo code could not be added to the order"})
+			return
+		}
+
+		publishOrder(ctx, "order.updated", orderId)
+
+		// response
+		c.JSON(http.StatusOK, discount)
+	}
+}
+
+func RemoveOrderDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// paid and cancelled orders keep their discounts
+		orderId := c.Param("order_id")
+		if err := orderOpenForPriceChanges(ctx, orderId); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+
+		// remove from the order
+		discountId := c.Param("discount_id")
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		var order models.Order
+		err := orderCollection.FindOneAndUpdate(ctx,
+			bson.M{"order_id": orderId, "discounts.discount_id": discountId},
+			bson.M{"$pull": bson.M{"discounts": bson.M{"discount_id": discountId}}, "$set": bson.M{"updated_at": updated_at}},
+		).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order discount was not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order discount was not removed"})
+			return
+		}
+
+		// give a promo code use back
+		for _, discount := range order.Discounts {
+			if discount.Discount_id == discountId && discount.Promo_code != nil {
+				releasePromoCode(ctx, *discount.Promo_code)
+			}
+		}
+
+		publishOrder(ctx, "order.updated", orderId)
+
+		// response
+		c.JSON(http.StatusOK, gin.H{"discount_id": discountId, "removed": true})
+	}
+}
+
+func SetOrderItemDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		discount, err := bindDiscount(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// paid and cancelled orders are not discounted
+		orderItemId := c.Param("order_item_id")
+		if err := orderItemOpenForChanges(ctx, orderItemId); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+
+		// set on the order item, replacing any previous discount
+		result, err := orderItemCollection.UpdateOne(ctx,
+			bson.M{"order_item_id": orderItemId},
+			bson.M{"$set": bson.M{"discount": discount, "updated_at": discount.Created_at}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order item discount was not set"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order item was not found"})
+			return
+		}
+
+		publishOrderItem(ctx, "order_item.updated", orderItemId)
+
+		// response
+		c.JSON(http.StatusOK, discount)
+	}
+}
+
+func RemoveOrderItemDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// paid and cancelled orders keep their discounts
+		orderItemId := c.Param("order_item_id")
+		if err := orderItemOpenForChanges(ctx, orderItemId); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+
+		// remove from the order item
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := orderItemCollection.UpdateOne(ctx,
+			bson.M{"order_item_id": orderItemId},
+			bson.M{"$set": bson.M{"discount": nil, "updated_at": updated_at}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "order item discount was not removed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order item was not found"})
+			return
+		}
+
+		publishOrderItem(ctx, "order_item.updated", orderItemId)
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// bindDiscount binds a manual discount authorized by the signed in user.
+func bindDiscount(c *gin.Context) (models.Discount, error) {
+	var discount models.Discount
+	if err := c.BindJSON(&discount); err != nil {
+		return discount, err
+	}
+	if err := validate.Struct(discount); err != nil {
+		return discount, err
+	}
+	if *discount.Kind == "PERCENT" && *discount.Value > 100 {
+		return discount, fmt.Errorf("a percentage discount must not exceed 100")
+	}
+
+	discount.Discount_id = primitive.NewObjectID().Hex()
+	discount.Promo_code = nil
+	discount.Authorized_by = c.GetString("uid")
+	discount.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	return discount, nil
+}
+
+func releasePromoCode(ctx context.Context, code string) {
+	promoCodeCollection.UpdateOne(ctx,
+		bson.M{"code": code, "used_count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"used_count": -1}},
+	)
+}
+
+// orderItemOpenForChanges returns an error when the order item's order is
+// paid or cancelled or has payments.
+func orderItemOpenForChanges(ctx context.Context, orderItemId string) error {
+	var orderItem models.OrderItem
+	if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem); err != nil {
+		return errOrderItemNotFound
+	}
+	return orderOpenForPriceChanges(ctx, orderItem.Order_id)
+}
+
+// orderChangeError responds to a change refused because of the order's status.
+func orderChangeError(c *gin.Context, err error) {
+	if err == errOrderNotFound || err == errOrderItemNotFound || err == errInvoiceNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+}
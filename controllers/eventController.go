@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"log"
+	"restaurant-management-backend/events"
+	"restaurant-management-backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// eventHeartbeat keeps idle event streams open through proxies.
+const eventHeartbeat = 15 * time.Second
+
+func StreamEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// subscribe with the requested filter
+		subscription := events.Subscribe(events.Filter{
+			Types:    splitQuery(c.Query("types")),
+			Station:  c.Query("station"),
+			Table_id: c.Query("table_id"),
+			Order_id: c.Query("order_id"),
+		})
+		defer subscription.Close()
+
+		heartbeat := time.NewTicker(eventHeartbeat)
+		defer heartbeat.Stop()
+
+		// stream as server-sent events until the client goes away
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.SSEvent("ready", gin.H{"at": time.Now()})
+		c.Writer.Flush()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case event, ok := <-subscription.C:
+				if !ok {
+					return false
+				}
+				c.SSEvent(event.Type, event)
+				return true
+			case at := <-heartbeat.C:
+				c.SSEvent("ping", gin.H{"at": at})
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
+
+// publishOrder publishes the order as it now is.
+func publishOrder(ctx context.Context, eventType string, orderId string) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		log.Println("event was not published:", eventType, orderId, err)
+		return
+	}
+	event := events.Event{Type: eventType, Order_id: order.Order_id, Data: order}
+	if order.Table_id != nil {
+		event.Table_id = *order.Table_id
+	}
+	events.Publish(event)
+}
+
+// publishOrderItem publishes the order item as it now is, with its order's
+// table and the stations its foods are made at.
+func publishOrderItem(ctx context.Context, eventType string, orderItemId string) {
+	var orderItem models.OrderItem
+	if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem); err != nil {
+		log.Println("event was not published:", eventType, orderItemId, err)
+		return
+	}
+	event := events.Event{Type: eventType, Order_id: orderItem.Order_id, Order_item_id: orderItem.Order_item_id, Data: orderItem}
+
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderItem.Order_id}).Decode(&order); err == nil && order.Table_id != nil {
+		event.Table_id = *order.Table_id
+	}
+	result, err := foodCollection.Find(ctx, bson.M{"food_id": bson.M{"$in": orderItemFoodIds(orderItem)}})
+	if err == nil {
+		var foods []models.Food
+		if err = result.All(ctx, &foods); err == nil {
+			for _, food := range foods {
+				if food.Station != nil {
+					event.Stations = append(event.Stations, *food.Station)
+				}
+			}
+		}
+	}
+	events.Publish(event)
+}
+
+// publishTable publishes the table as it now is.
+func publishTable(ctx context.Context, eventType string, tableId string) {
+	var table models.Table
+	if err := tableCollection.FindOne(ctx, bson.M{"table_id": tableId}).Decode(&table); err != nil {
+		log.Println("event was not published:", eventType, tableId, err)
+		return
+	}
+	events.Publish(events.Event{Type: eventType, Table_id: table.Table_id, Data: table})
+}
+
+// publishInvoice publishes the invoice as it now is, with its order's table.
+func publishInvoice(ctx context.Context, eventType string, invoiceId string) {
+	var invoice models.Invoice
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		log.Println("event was not published:", eventType, invoiceId, err)
+		return
+	}
+	event := events.Event{Type: eventType, Order_id: invoice.Order_id, Invoice_id: invoice.Invoice_id, Data: invoice}
+
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err == nil && order.Table_id != nil {
+		event.Table_id = *order.Table_id
+	}
+	events.Publish(event)
+}
@@ -7,8 +7,10 @@ import (
 	"math"
 	"net/http"
 	"restaurant-management-backend/database"
+	"restaurant-management-backend/helper"
 	"restaurant-management-backend/models"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,7 +22,24 @@ import (
 )
 
 var foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
-var validate = validator.New()
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("allergen", func(fl validator.FieldLevel) bool {
+		return models.IsAllergen(fl.Field().String())
+	})
+	v.RegisterValidation("dietary_tag", func(fl validator.FieldLevel) bool {
+		return models.IsDietaryTag(fl.Field().String())
+	})
+	v.RegisterValidation("station", func(fl validator.FieldLevel) bool {
+		return models.IsStation(fl.Field().String())
+	})
+	v.RegisterValidation("language_tag", func(fl validator.FieldLevel) bool {
+		return helper.IsLanguageTag(fl.Field().String())
+	})
+	return v
+}
 
 func GetFoods() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -45,24 +64,42 @@ func GetFoods() gin.HandlerFunc {
 			return
 		}
 
+		// Build the filter and sort order from the query parameters
+		filter, err := foodFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sort, err := foodSort(c.Query("sort"), c.Query("q") != "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// MongoDB aggregation pipeline stages
-		matchStage := bson.D{{"$match", bson.D{{}}}}
-		groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"_id", "null"}}}, {"total_count", bson.D{{"$sum", 1}}}, {"data", bson.D{{"$push", "$$ROOT"}}}}}}
+		matchStage := bson.D{{Key: "$match", Value: filter}}
+		facetStage := bson.D{
+			{Key: "$facet", Value: bson.D{
+				{Key: "total_count", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+				{Key: "food_items", Value: bson.A{
+					bson.D{{Key: "$sort", Value: sort}},
+					bson.D{{Key: "$skip", Value: startIndex}},
+					bson.D{{Key: "$limit", Value: recordPerPage}},
+				}},
+			}},
+		}
 		projectStage := bson.D{
-			{
-				"$project", bson.D{
-					{"_id", 0},
-					{"total_count", 1},
-					{"food_items", bson.D{{"$slice", []interface{}{"$data", startIndex, recordPerPage}}}},
-				},
-			},
+			{Key: "$project", Value: bson.D{
+				{Key: "total_count", Value: bson.D{{Key: "$ifNull", Value: bson.A{bson.D{{Key: "$arrayElemAt", Value: bson.A{"$total_count.count", 0}}}, 0}}}},
+				{Key: "food_items", Value: 1},
+			}},
 		}
 
 		// Perform MongoDB aggregation using the defined pipeline
 		result, err := foodCollection.Aggregate(ctx, mongo.Pipeline{
-			matchStage, groupStage, projectStage,
+			matchStage, localizeStage(c, "name", "description"), facetStage, projectStage,
 		})
-		defer cancel()
 
 		// Handle errors during the MongoDB aggregation
 		if err != nil {
@@ -73,7 +110,8 @@ func GetFoods() gin.HandlerFunc {
 		// Decode the result into a slice of BSON documents
 		var allFoods []bson.M
 		if err = result.All(ctx, &allFoods); err != nil {
-			log.Fatal(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred while listing food items"})
+			return
 		}
 
 		// Respond with the paginated list of food items
@@ -87,14 +125,26 @@ func GetFood() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		// retrieve and decode
+		// retrieve in the requested language and decode
 		foodId := c.Param("food_id")
-		var food models.Food
-
-		err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food)
+		result, err := foodCollection.Aggregate(ctx, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: bson.M{"food_id": foodId}}},
+			localizeStage(c, "name", "description"),
+		})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching the food item"})
+			return
+		}
+		var foods []models.Food
+		if err = result.All(ctx, &foods); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching the food item"})
+			return
+		}
+		if len(foods) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "food item was not found"})
+			return
 		}
+		food := foods[0]
 
 		// response
 		c.JSON(http.StatusOK, food)
@@ -119,6 +169,10 @@ func CreateFood() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
 			return
 		}
+		if err := validateNutritionSizes(food.Nutrition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		// TODO: use go routine
 		var menu models.Menu
@@ -134,6 +188,14 @@ func CreateFood() gin.HandlerFunc {
 		food.Food_id = food.ID.Hex()
 		var num = toFixed(*food.Price, 2)
 		food.Price = &num
+		if food.Is_available == nil {
+			available := true
+			food.Is_available = &available
+		}
+		if food.Tax_category == nil {
+			category := defaultTaxCategory
+			food.Tax_category = &category
+		}
 
 		// inserting
 		result, insertErr := foodCollection.InsertOne(ctx, food)
@@ -142,6 +204,9 @@ func CreateFood() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		if err := recordPriceChange(ctx, food.Food_id, nil, food.Price, c.GetString("uid")); err != nil {
+			log.Println("price history was not recorded:", food.Food_id, err)
+		}
 
 		// response
 		c.JSON(http.StatusOK, result)
@@ -176,14 +241,100 @@ func UpdateFood() gin.HandlerFunc {
 			updateObj = append(updateObj, bson.E{"name", food.Name})
 		}
 
+		if food.Nutrition != nil {
+			if err := validate.Var(food.Nutrition, "dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := validateNutritionSizes(food.Nutrition); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "nutrition", Value: food.Nutrition})
+		}
+
+		if food.Translations != nil {
+			if err := validate.Var(food.Translations, "dive,keys,language_tag,endkeys"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "translations", Value: food.Translations})
+		}
+
+		if food.Description != nil {
+			if err := validate.Var(*food.Description, "max=500"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "description", Value: food.Description})
+		}
+
+		if food.Category != nil {
+			updateObj = append(updateObj, bson.E{Key: "category", Value: food.Category})
+		}
+
+		if food.Tax_category != nil {
+			if err := validate.Var(*food.Tax_category, "eq=FOOD|eq=ALCOHOL|eq=TAKEAWAY"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "tax_category must be one of FOOD, ALCOHOL or TAKEAWAY"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "tax_category", Value: food.Tax_category})
+		}
+
+		if food.Station != nil {
+			if !models.IsStation(*food.Station) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "station must be one of grill, fryer, bar or cold"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "station", Value: food.Station})
+		}
+
+		if food.Is_available != nil {
+			// setting availability by hand overrides the low-stock state
+			updateObj = append(updateObj, bson.E{Key: "is_available", Value: food.Is_available})
+			updateObj = append(updateObj, bson.E{Key: "unavailable_reason", Value: nil})
+		}
+
+		if food.Display_position != nil {
+			if *food.Display_position < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "display_position must not be negative"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "display_position", Value: food.Display_position})
+		}
+
+		// A new price is recorded in the price history once the update succeeds
+		var previousPrice *float64
 		if food.Price != nil {
-			updateObj = append(updateObj, bson.E{"price", food.Price})
+			var current models.Food
+			if err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&current); err == nil {
+				previousPrice = current.Price
+			}
+			var num = toFixed(*food.Price, 2)
+			food.Price = &num
+			updateObj = append(updateObj, bson.E{Key: "price", Value: food.Price})
 		}
 
 		if food.Food_image != nil {
 			updateObj = append(updateObj, bson.E{"food_image", food.Food_image})
 		}
 
+		if food.Allergens != nil {
+			if err := validate.Var(food.Allergens, "dive,allergen"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "allergens", Value: food.Allergens})
+		}
+
+		if food.Dietary_tags != nil {
+			if err := validate.Var(food.Dietary_tags, "dive,dietary_tag"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "dietary_tags", Value: food.Dietary_tags})
+		}
+
 		if food.Menu_id != nil {
 			// If Menu ID is provided, check if the menu exists
 			err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
@@ -193,7 +344,7 @@ func UpdateFood() gin.HandlerFunc {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 				return
 			}
-			updateObj = append(updateObj, bson.E{"menu", food.Menu_id})
+			updateObj = append(updateObj, bson.E{Key: "menu_id", Value: food.Menu_id})
 		}
 
 		// Update the "updated_at" field with the current time
@@ -224,11 +375,214 @@ func UpdateFood() gin.HandlerFunc {
 			return
 		}
 
+		if food.Price != nil && (previousPrice == nil || *previousPrice != *food.Price) {
+			if err := recordPriceChange(ctx, foodId, previousPrice, food.Price, c.GetString("uid")); err != nil {
+				log.Println("price history was not recorded:", foodId, err)
+			}
+		}
+
 		// Respond with the result of the update operation
 		c.JSON(http.StatusOK, result)
 	}
 }
 
+// EnsureFoodIndexes creates the indexes backing the GetFoods filters, text
+// search and sort orders. It is safe to call on every start.
+func EnsureFoodIndexes() error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := foodCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "food_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}}},
+		{Keys: bson.D{{Key: "menu_id", Value: 1}, {Key: "category", Value: 1}, {Key: "display_position", Value: 1}}},
+		{Keys: bson.D{{Key: "price", Value: 1}}},
+		{Keys: bson.D{{Key: "is_available", Value: 1}}},
+		{Keys: bson.D{{Key: "allergens", Value: 1}}},
+		{Keys: bson.D{{Key: "dietary_tags", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// foodFilter builds the GetFoods filter from the "q", "menu_id", "category",
+// "min_price", "max_price" and "available" query parameters on top of the
+// allergen and dietary filter.
+func foodFilter(c *gin.Context) (bson.M, error) {
+	filter, err := dietaryFilter(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		filter["$text"] = bson.M{"$search": q}
+	}
+	if menuId := c.Query("menu_id"); menuId != "" {
+		filter["menu_id"] = menuId
+	}
+	if category := c.Query("category"); category != "" {
+		filter["category"] = category
+	}
+
+	price := bson.M{}
+	if minPrice := c.Query("min_price"); minPrice != "" {
+		value, err := strconv.ParseFloat(minPrice, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_price: %s", minPrice)
+		}
+		price["$gte"] = value
+	}
+	if maxPrice := c.Query("max_price"); maxPrice != "" {
+		value, err := strconv.ParseFloat(maxPrice, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_price: %s", maxPrice)
+		}
+		price["$lte"] = value
+	}
+	if len(price) > 0 {
+		filter["price"] = price
+	}
+
+	if available := c.Query("available"); available != "" {
+		value, err := strconv.ParseBool(available)
+		if err != nil {
+			return nil, fmt.Errorf("invalid available: %s", available)
+		}
+		if value {
+			// foods created before availability existed are available
+			filter["is_available"] = bson.M{"$ne": false}
+		} else {
+			filter["is_available"] = false
+		}
+	}
+
+	return filter, nil
+}
+
+// foodSort maps the "sort" query parameter (a field optionally prefixed
+// with "-" for descending order) to a sort document. Text searches are
+// ordered by relevance unless a sort order is given.
+func foodSort(value string, textSearch bool) (bson.D, error) {
+	if value == "" {
+		if textSearch {
+			return bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: 1}}, nil
+		}
+		return bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}, nil
+	}
+
+	order := 1
+	field := value
+	if strings.HasPrefix(value, "-") {
+		order = -1
+		field = strings.TrimPrefix(value, "-")
+	}
+
+	switch field {
+	case "name", "price", "created_at", "updated_at", "category":
+		return bson.D{{Key: field, Value: order}, {Key: "_id", Value: 1}}, nil
+	}
+	return nil, fmt.Errorf("invalid sort: %s", value)
+}
+
+// validateNutritionSizes allows at most one set of nutrition facts per size.
+func validateNutritionSizes(nutrition []models.NutritionFacts) error {
+	seen := map[string]bool{}
+	for _, facts := range nutrition {
+		size := "all sizes"
+		if facts.Size != nil {
+			size = *facts.Size
+		}
+		if seen[size] {
+			return fmt.Errorf("duplicate nutrition facts for %s", size)
+		}
+		seen[size] = true
+	}
+	return nil
+}
+
+// nutritionFor picks the food's nutrition facts for a size, falling back to
+// its sizeless facts.
+func nutritionFor(food models.Food, size *string) *models.NutritionFacts {
+	var fallback *models.NutritionFacts
+	for i, facts := range food.Nutrition {
+		if facts.Size == nil {
+			fallback = &food.Nutrition[i]
+		} else if size != nil && *facts.Size == *size {
+			return &food.Nutrition[i]
+		}
+	}
+	return fallback
+}
+
+// localizeStage replaces fields with their translation in the languages the
+// request prefers, falling back to the untranslated default language value.
+// Translations are matched whatever the case of the language tag they are
+// keyed by.
+func localizeStage(c *gin.Context, fields ...string) bson.D {
+	languages := helper.RequestLanguages(c.Query("lang"), c.GetHeader("Accept-Language"))
+
+	localized := bson.D{}
+	for _, field := range fields {
+		var value interface{} = "$" + field
+		for i := len(languages) - 1; i >= 0; i-- {
+			value = bson.D{{Key: "$ifNull", Value: bson.A{translationField(languages[i], field), value}}}
+		}
+		localized = append(localized, bson.E{Key: field, Value: value})
+	}
+	return bson.D{{Key: "$addFields", Value: localized}}
+}
+
+// translationField is field translated into language, a lower-case tag, or
+// missing when there is no such translation.
+func translationField(language string, field string) bson.D {
+	return bson.D{{Key: "$let", Value: bson.D{
+		{Key: "vars", Value: bson.D{{Key: "translation", Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{
+			bson.D{{Key: "$filter", Value: bson.D{
+				{Key: "input", Value: bson.D{{Key: "$objectToArray", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$translations", bson.D{}}}}}}},
+				{Key: "cond", Value: bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$toLower", Value: "$$this.k"}}, language}}}},
+			}}},
+			0,
+		}}}}}},
+		{Key: "in", Value: "$$translation.v." + field},
+	}}}
+}
+
+// dietaryFilter reads the "allergen_free" (contains none of) and "dietary"
+// (has all of) query parameters, both comma separated, into a food filter.
+func dietaryFilter(c *gin.Context) (bson.M, error) {
+	filter := bson.M{}
+
+	if allergenFree := splitQuery(c.Query("allergen_free")); len(allergenFree) > 0 {
+		for _, allergen := range allergenFree {
+			if !models.IsAllergen(allergen) {
+				return nil, fmt.Errorf("unknown allergen: %s", allergen)
+			}
+		}
+		filter["allergens"] = bson.M{"$nin": allergenFree}
+	}
+
+	if dietary := splitQuery(c.Query("dietary")); len(dietary) > 0 {
+		for _, tag := range dietary {
+			if !models.IsDietaryTag(tag) {
+				return nil, fmt.Errorf("unknown dietary tag: %s", tag)
+			}
+		}
+		filter["dietary_tags"] = bson.M{"$all": dietary}
+	}
+
+	return filter, nil
+}
+
+func splitQuery(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 func round(num float64) int {
 	return int(num + math.Copysign(0.5, num))
 }
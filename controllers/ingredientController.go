@@ -0,0 +1,342 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const lowStockReason = "LOW_STOCK"
+
+var ingredientCollection *mongo.Collection = database.OpenCollection(database.Client, "ingredient")
+
+func GetIngredients() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve, optionally only the ones at or below their threshold
+		filter := bson.M{}
+		if c.Query("low_stock") == "true" {
+			filter["$expr"] = bson.M{"$lte": bson.A{"$on_hand", "$low_stock_threshold"}}
+			filter["low_stock_threshold"] = bson.M{"$ne": nil}
+		}
+		result, err := ingredientCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing ingredients"})
+			return
+		}
+
+		// decode
+		allIngredients := []bson.M{}
+		if err = result.All(ctx, &allIngredients); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing ingredients"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allIngredients)
+	}
+}
+
+func GetIngredient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve and decode
+		ingredientId := c.Param("ingredient_id")
+		var ingredient models.Ingredient
+		if err := ingredientCollection.FindOne(ctx, bson.M{"ingredient_id": ingredientId}).Decode(&ingredient); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "ingredient was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, ingredient)
+	}
+}
+
+func CreateIngredient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var ingredient models.Ingredient
+		if err := c.BindJSON(&ingredient); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(ingredient); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if ingredient.Supplier_id != nil {
+			count, err := supplierCollection.CountDocuments(ctx, bson.M{"supplier_id": ingredient.Supplier_id})
+			if err != nil || count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "supplier was not found"})
+				return
+			}
+		}
+
+		ingredient.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		ingredient.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		ingredient.ID = primitive.NewObjectID()
+		ingredient.Ingredient_id = ingredient.ID.Hex()
+		if ingredient.On_hand == nil {
+			var none float64
+			ingredient.On_hand = &none
+		}
+
+		// insert
+		result, err := ingredientCollection.InsertOne(ctx, ingredient)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ingredient was not created"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateIngredient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind
+		var ingredient models.Ingredient
+		if err := c.BindJSON(&ingredient); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// prepare updated obj
+		var updateObj primitive.D
+		if ingredient.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: ingredient.Name})
+		}
+		if ingredient.Unit != nil {
+			if err := validate.Var(*ingredient.Unit, "eq=g|eq=kg|eq=ml|eq=l|eq=pcs"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "unit", Value: ingredient.Unit})
+		}
+		if ingredient.On_hand != nil {
+			// stock only changes through movements, so corrections show up in the variance
+			c.JSON(http.StatusBadRequest, gin.H{"error": "on_hand cannot be updated, record a stock count with POST /inventory/counts"})
+			return
+		}
+		if ingredient.Low_stock_threshold != nil {
+			if *ingredient.Low_stock_threshold < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "low_stock_threshold must not be negative"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "low_stock_threshold", Value: ingredient.Low_stock_threshold})
+		}
+		if ingredient.Par_level != nil {
+			if *ingredient.Par_level < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "par_level must not be negative"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "par_level", Value: ingredient.Par_level})
+		}
+		if ingredient.Supplier_id != nil {
+			count, err := supplierCollection.CountDocuments(ctx, bson.M{"supplier_id": ingredient.Supplier_id})
+			if err != nil || count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "supplier was not found"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "supplier_id", Value: ingredient.Supplier_id})
+		}
+		if ingredient.Auto_disable_foods != nil {
+			updateObj = append(updateObj, bson.E{Key: "auto_disable_foods", Value: ingredient.Auto_disable_foods})
+		}
+		ingredient.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: ingredient.Updated_at})
+
+		// update mongodb
+		ingredientId := c.Param("ingredient_id")
+		result, err := ingredientCollection.UpdateOne(
+			ctx,
+			bson.M{"ingredient_id": ingredientId},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "ingredient update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "ingredient was not found"})
+			return
+		}
+
+		// a new threshold can change which foods are available
+		if err := refreshStockAvailability(ctx, []string{ingredientId}); err != nil {
+			log.Println("stock availability refresh failed:", err)
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// DepleteStock deducts the recipe amounts of the given order items from the
+// ingredients on hand and records them as sales. Combo items deplete the
+// recipes of their components.
+func DepleteStock(ctx context.Context, orderItems []models.OrderItem, recordedBy string) error {
+	return bookSales(ctx, orderItems, recordedBy, 1)
+}
+
+// RestoreStock puts back what DepleteStock took for order items that will not
+// be made as ordered, booking negative sales the variance report nets out.
+func RestoreStock(ctx context.Context, orderItems []models.OrderItem, recordedBy string) error {
+	return bookSales(ctx, orderItems, recordedBy, -1)
+}
+
+// bookSales moves the recipe amounts of the given order items out of stock,
+// or back in when sign is negative, and records them as sales.
+func bookSales(ctx context.Context, orderItems []models.OrderItem, recordedBy string, sign float64) error {
+	usage, err := recipeUsage(ctx, orderItems)
+	if err != nil {
+		return err
+	}
+	if len(usage) == 0 {
+		return nil
+	}
+	for ingredientId := range usage {
+		usage[ingredientId] *= sign
+	}
+
+	var writes []mongo.WriteModel
+	var ingredientIds []string
+	for ingredientId, amount := range usage {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"ingredient_id": ingredientId}).
+			SetUpdate(bson.M{"$inc": bson.M{"on_hand": -amount}}))
+		ingredientIds = append(ingredientIds, ingredientId)
+	}
+	if _, err := ingredientCollection.BulkWrite(ctx, writes); err != nil {
+		return err
+	}
+
+	// record the sales against the order for the variance report
+	recorded_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	movements := []interface{}{}
+	for ingredientId, amount := range usage {
+		ingredientId, amount := ingredientId, amount
+		movement := models.StockMovement{
+			Ingredient_id: &ingredientId,
+			Type:          "SALE",
+			Quantity:      &amount,
+			Change:        -amount,
+			Reference:     &orderItems[0].Order_id,
+			Recorded_by:   recordedBy,
+			Recorded_at:   recorded_at,
+			Created_at:    recorded_at,
+			ID:            primitive.NewObjectID(),
+		}
+		movement.Movement_id = movement.ID.Hex()
+		movements = append(movements, movement)
+	}
+	if _, err := stockMovementCollection.InsertMany(ctx, movements); err != nil {
+		return err
+	}
+
+	return refreshStockAvailability(ctx, ingredientIds)
+}
+
+// recipeUsage sums the ingredient amounts used by the given order items.
+func recipeUsage(ctx context.Context, orderItems []models.OrderItem) (map[string]float64, error) {
+	usage := map[string]float64{}
+	for _, orderItem := range orderItems {
+		for _, foodId := range orderItemFoodIds(orderItem) {
+			recipe, err := recipeFor(ctx, foodId, orderItem.Quantity)
+			if err == mongo.ErrNoDocuments {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, line := range recipe.Lines {
+				usage[line.Ingredient_id] += line.Amount
+			}
+		}
+	}
+	return usage, nil
+}
+
+// refreshStockAvailability marks the foods that depend on the given
+// ingredients unavailable while any of their auto-disabling ingredients is at
+// or below its low-stock threshold, and available again once restocked.
+// Foods made unavailable by hand are left alone.
+func refreshStockAvailability(ctx context.Context, ingredientIds []string) error {
+	foodIds, err := recipeCollection.Distinct(ctx, "food_id", bson.M{"lines.ingredient_id": bson.M{"$in": ingredientIds}})
+	if err != nil || len(foodIds) == 0 {
+		return err
+	}
+
+	// find the low-stock ingredients that disable foods
+	result, err := ingredientCollection.Find(ctx, bson.M{
+		"auto_disable_foods":  true,
+		"low_stock_threshold": bson.M{"$ne": nil},
+		"$expr":               bson.M{"$lte": bson.A{"$on_hand", "$low_stock_threshold"}},
+	})
+	if err != nil {
+		return err
+	}
+	var lowIngredients []models.Ingredient
+	if err = result.All(ctx, &lowIngredients); err != nil {
+		return err
+	}
+	var lowIds []string
+	for _, ingredient := range lowIngredients {
+		lowIds = append(lowIds, ingredient.Ingredient_id)
+	}
+
+	// foods with a recipe using a low-stock ingredient
+	lowFoodIds := []interface{}{}
+	if len(lowIds) > 0 {
+		lowFoodIds, err = recipeCollection.Distinct(ctx, "food_id", bson.M{
+			"food_id":             bson.M{"$in": foodIds},
+			"lines.ingredient_id": bson.M{"$in": lowIds},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if len(lowFoodIds) > 0 {
+		_, err = foodCollection.UpdateMany(ctx,
+			bson.M{"food_id": bson.M{"$in": lowFoodIds}, "is_available": bson.M{"$ne": false}},
+			bson.M{"$set": bson.M{"is_available": false, "unavailable_reason": lowStockReason, "updated_at": updated_at}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = foodCollection.UpdateMany(ctx,
+		bson.M{"food_id": bson.M{"$in": foodIds, "$nin": lowFoodIds}, "unavailable_reason": lowStockReason},
+		bson.M{"$set": bson.M{"is_available": true, "unavailable_reason": nil, "updated_at": updated_at}},
+	)
+	return err
+}
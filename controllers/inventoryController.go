@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const wasteReasons = "eq=SPOILAGE|eq=EXPIRED|eq=DAMAGED|eq=PREP_ERROR|eq=RETURNED|eq=OTHER"
+
+var stockMovementCollection *mongo.Collection = database.OpenCollection(database.Client, "stockMovement")
+
+func RecordReceipt() gin.HandlerFunc {
+	return recordMovementHandler("RECEIPT")
+}
+
+func RecordCount() gin.HandlerFunc {
+	return recordMovementHandler("COUNT")
+}
+
+func RecordWaste() gin.HandlerFunc {
+	return recordMovementHandler("WASTE")
+}
+
+func GetStockMovements() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// filter by period, ingredient and type
+		from, to, err := reportPeriod(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter := bson.M{"recorded_at": bson.M{"$gte": from, "$lt": to}}
+		if ingredientId := c.Query("ingredient_id"); ingredientId != "" {
+			filter["ingredient_id"] = ingredientId
+		}
+		if movementType := c.Query("type"); movementType != "" {
+			filter["type"] = movementType
+		}
+
+		// retrieve
+		result, err := stockMovementCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"recorded_at": -1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing stock movements"})
+			return
+		}
+
+		// decode
+		allMovements := []bson.M{}
+		if err = result.All(ctx, &allMovements); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing stock movements"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allMovements)
+	}
+}
+
+func GetVarianceReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// filter by period and ingredient
+		from, to, err := reportPeriod(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		match := bson.M{"recorded_at": bson.M{"$gte": from, "$lt": to}}
+		if ingredientId := c.Query("ingredient_id"); ingredientId != "" {
+			match["ingredient_id"] = ingredientId
+		}
+
+		// sum the movements of each ingredient by type
+		sumOfType := func(movementType string, field interface{}) bson.D {
+			return bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{"$type", movementType}}},
+				bson.D{{Key: "$ifNull", Value: bson.A{field, 0}}},
+				0,
+			}}}}}
+		}
+		result, err := stockMovementCollection.Aggregate(ctx, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: match}},
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$ingredient_id"},
+				{Key: "received", Value: sumOfType("RECEIPT", "$quantity")},
+				{Key: "sold", Value: sumOfType("SALE", "$quantity")},
+				{Key: "wasted", Value: sumOfType("WASTE", "$quantity")},
+				{Key: "variance", Value: sumOfType("COUNT", "$variance")},
+				{Key: "counts", Value: sumOfType("COUNT", 1)},
+			}}},
+			bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "ingredient"}, {Key: "localField", Value: "_id"}, {Key: "foreignField", Value: "ingredient_id"}, {Key: "as", Value: "ingredient"}}}},
+			bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$ingredient"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}},
+			bson.D{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "ingredient_id", Value: "$_id"},
+				{Key: "name", Value: "$ingredient.name"},
+				{Key: "unit", Value: "$ingredient.unit"},
+				{Key: "on_hand", Value: "$ingredient.on_hand"},
+				{Key: "received", Value: 1},
+				{Key: "sold", Value: 1},
+				{Key: "wasted", Value: 1},
+				{Key: "counts", Value: 1},
+				{Key: "variance", Value: 1},
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the variance report"})
+			return
+		}
+
+		// decode
+		ingredients := []bson.M{}
+		if err = result.All(ctx, &ingredients); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the variance report"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "ingredients": ingredients})
+	}
+}
+
+func recordMovementHandler(movementType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var movement models.StockMovement
+		if err := c.BindJSON(&movement); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		movement.Type = movementType
+		if err := validate.Struct(movement); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if movementType == "WASTE" {
+			if movement.Reason == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "a waste reason is required"})
+				return
+			}
+			if err := validate.Var(*movement.Reason, wasteReasons); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid waste reason: " + *movement.Reason})
+				return
+			}
+		}
+		movement.Recorded_by = c.GetString("uid")
+
+		// apply to the ingredient and record
+		movement, err := recordStockMovement(ctx, movement)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "ingredient was not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "stock movement was not recorded"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, movement)
+	}
+}
+
+// recordStockMovement applies a receipt, count or waste movement to the
+// ingredient's quantity on hand and records it. A count sets the quantity
+// on hand and records the variance against the quantity expected before it.
+func recordStockMovement(ctx context.Context, movement models.StockMovement) (models.StockMovement, error) {
+	var update bson.M
+	switch movement.Type {
+	case "RECEIPT":
+		movement.Change = *movement.Quantity
+		update = bson.M{"$inc": bson.M{"on_hand": movement.Change}}
+	case "WASTE":
+		movement.Change = -*movement.Quantity
+		update = bson.M{"$inc": bson.M{"on_hand": movement.Change}}
+	case "COUNT":
+		update = bson.M{"$set": bson.M{"on_hand": *movement.Quantity}}
+	default:
+		return movement, fmt.Errorf("unsupported stock movement: %s", movement.Type)
+	}
+
+	var before models.Ingredient
+	err := ingredientCollection.FindOneAndUpdate(ctx, bson.M{"ingredient_id": movement.Ingredient_id}, update).Decode(&before)
+	if err != nil {
+		return movement, err
+	}
+
+	if movement.Type == "COUNT" {
+		var expected float64
+		if before.On_hand != nil {
+			expected = *before.On_hand
+		}
+		variance := toFixed(*movement.Quantity-expected, 3)
+		movement.Expected = &expected
+		movement.Variance = &variance
+		movement.Change = variance
+	}
+
+	movement.Recorded_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	movement.Created_at = movement.Recorded_at
+	movement.ID = primitive.NewObjectID()
+	movement.Movement_id = movement.ID.Hex()
+	if _, err := stockMovementCollection.InsertOne(ctx, movement); err != nil {
+		return movement, err
+	}
+
+	if err := refreshStockAvailability(ctx, []string{*movement.Ingredient_id}); err != nil {
+		log.Println("stock availability refresh failed:", err)
+	}
+	return movement, nil
+}
+
+// reverseStockMovement undoes a receipt or waste movement that was recorded
+// as part of something that failed, removing it from the movement log.
+func reverseStockMovement(ctx context.Context, movement models.StockMovement) error {
+	if _, err := ingredientCollection.UpdateOne(ctx,
+		bson.M{"ingredient_id": movement.Ingredient_id},
+		bson.M{"$inc": bson.M{"on_hand": -movement.Change}},
+	); err != nil {
+		return err
+	}
+	if _, err := stockMovementCollection.DeleteOne(ctx, bson.M{"movement_id": movement.Movement_id}); err != nil {
+		return err
+	}
+	return refreshStockAvailability(ctx, []string{*movement.Ingredient_id})
+}
+
+// reportPeriod reads the "from" and "to" query parameters as RFC3339 times or
+// dates, a date "to" including the whole day. It defaults to the last 30 days.
+func reportPeriod(c *gin.Context) (from time.Time, to time.Time, err error) {
+	to = time.Now()
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			if to, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+				return from, to, fmt.Errorf("invalid to: %s", value)
+			}
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	from = to.AddDate(0, 0, -30)
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			if from, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+				return from, to, fmt.Errorf("invalid from: %s", value)
+			}
+		}
+	}
+
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
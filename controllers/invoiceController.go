@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"restaurant-management-backend/database"
+	"restaurant-management-backend/helper"
 	"restaurant-management-backend/models"
 	"time"
 
@@ -25,6 +26,17 @@ type InvoiceViewFormat struct {
 	Table_number     interface{}
 	Payment_due_date time.Time
 	Order_details    interface{}
+	Subtotal         float64
+	Discounts        []helper.InvoiceDiscount
+	Discount_total   float64
+	Tax_total        float64
+	Service_charge   float64
+	Tip_total        float64
+	Tips             []models.Tip
+	Amount_paid      float64
+	Balance_due      float64
+	Amount_refunded  float64
+	Totals           helper.InvoiceTotals
 }
 
 var invoiceCollection *mongo.Collection = database.OpenCollection(database.Client, "invoice")
@@ -73,6 +85,15 @@ func GetInvoice() gin.HandlerFunc {
 		var invoiceView InvoiceViewFormat
 
 		allOrderItems, err := ItemsByOrder(invoice.Order_id)
+		if err != nil || len(allOrderItems) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no order items were found for this invoice"})
+			return
+		}
+		totals, err := invoiceTotals(ctx, invoice)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while calculating the invoice totals"})
+			return
+		}
 		invoiceView.Order_id = invoice.Order_id
 		invoiceView.Payment_due_date = invoice.Payment_due_date
 
@@ -83,9 +104,36 @@ func GetInvoice() gin.HandlerFunc {
 
 		invoiceView.Invoice_id = invoice.Invoice_id
 		invoiceView.Payment_status = *&invoice.Payment_status
-		invoiceView.Payment_due = allOrderItems[0]["payment_due"]
+		invoiceView.Payment_due = totals.Total
+		invoiceView.Subtotal = totals.Subtotal
+		invoiceView.Discounts = totals.Discounts
+		invoiceView.Discount_total = totals.Discount_total
+		invoiceView.Tax_total = totals.Tax_total
+		invoiceView.Service_charge = totals.Service_charge
+		invoiceView.Tip_total = totals.Tip_total
+		invoiceView.Tips = invoice.Tips
+		if invoiceView.Tips == nil {
+			invoiceView.Tips = []models.Tip{}
+		}
+		invoiceView.Totals = totals
+		paid, err := invoicePaid(ctx, invoice.Invoice_id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the invoice's payments"})
+			return
+		}
+		invoiceView.Amount_paid = helper.FromCents(paid)
+		invoiceView.Balance_due = helper.FromCents(max(helper.ToCents(totals.Total_with_tips)-paid, 0))
+		refunded, err := refundedAmount(ctx, bson.M{"invoice_id": invoice.Invoice_id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the invoice's refunds"})
+			return
+		}
+		invoiceView.Amount_refunded = helper.FromCents(refunded)
 		invoiceView.Table_number = allOrderItems[0]["table_number"]
 		invoiceView.Order_details = allOrderItems[0]["order_items"]
+		if invoice.Split != nil && invoice.Split.Mode != "EVEN" {
+			invoiceView.Order_details = splitOrderDetails(allOrderItems[0]["order_items"], invoice.Split.Order_item_ids)
+		}
 
 		// response
 		c.JSON(http.StatusOK, invoiceView)
@@ -114,15 +162,23 @@ func CreateInvoice() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		// invoices are paid by recording payments
 		status := "PENDING"
-		if invoice.Payment_status == nil {
-			invoice.Payment_status = &status
-		}
+		invoice.Payment_status = &status
+		invoice.Split = nil
 		invoice.Payment_due_date, _ = time.Parse(time.RFC3339, time.Now().AddDate(0, 0, 1).Format(time.RFC3339))
 		invoice.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.ID = primitive.NewObjectID()
 		invoice.Invoice_id = invoice.ID.Hex()
+		invoice.Tips = []models.Tip{}
+
+		// large parties are charged for service
+		invoice.Service_charge, err = serviceChargeFor(ctx, order)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while looking up the service charge"})
+			return
+		}
 
 		// validate
 		validationErr := validate.Struct(invoice)
@@ -131,6 +187,21 @@ func CreateInvoice() gin.HandlerFunc {
 			return
 		}
 
+		// only served orders are billed
+		if status := orderStatus(order); status != "SERVED" {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("a %s order cannot be invoiced", status)})
+			return
+		}
+		invoices, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": order.Order_id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the order's invoices"})
+			return
+		}
+		if invoices > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "the order is already invoiced"})
+			return
+		}
+
 		// insert
 		result, insertErr := invoiceCollection.InsertOne(ctx, invoice)
 		if insertErr != nil {
@@ -138,6 +209,13 @@ func CreateInvoice() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		if _, err := transitionOrder(ctx, order.Order_id, "BILLED", c.GetString("uid"), nil); err != nil {
+			invoiceCollection.DeleteOne(ctx, bson.M{"invoice_id": invoice.Invoice_id})
+			orderChangeError(c, err)
+			return
+		}
+
+		publishInvoice(ctx, "invoice.created", invoice.Invoice_id)
 
 		// response
 		c.JSON(http.StatusOK, result)
@@ -165,7 +243,54 @@ func UpdateInvoice() gin.HandlerFunc {
 		}
 
 		if invoice.Payment_status != nil {
-			updateObj = append(updateObj, bson.E{"payment_status", invoice.Payment_status})
+			if err := validate.Var(*invoice.Payment_status, "eq=PENDING|eq=PAID"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "payment_status must be PENDING or PAID"})
+				return
+			}
+		}
+
+		// the payment status follows the invoice's payments, recorded at
+		// /invoices/:invoice_id/payments; only an invoice with nothing to pay
+		// is set PAID here
+		invoiceId := c.Param("invoice_id")
+		var existing models.Invoice
+		paying := false
+		if invoice.Payment_status != nil {
+			if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&existing); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": errInvoiceNotFound.Error()})
+				return
+			}
+			alreadyPaid := existing.Payment_status != nil && *existing.Payment_status == "PAID"
+			if *invoice.Payment_status == "PAID" && !alreadyPaid {
+				if _, err := payableInvoice(ctx, invoiceId); err != nil {
+					orderChangeError(c, err)
+					return
+				}
+				due, paid, err := invoiceBalance(ctx, existing)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while calculating the invoice totals"})
+					return
+				}
+				if paid < due {
+					c.JSON(http.StatusConflict, gin.H{"error": "pay the invoice by recording payments at /invoices/:invoice_id/payments"})
+					return
+				}
+				// e.g. when every item was comped
+				updateObj = append(updateObj, bson.E{Key: "payment_status", Value: invoice.Payment_status})
+				paying = true
+			}
+			if *invoice.Payment_status == "PENDING" {
+				paid, err := invoicePaid(ctx, invoiceId)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the invoice's payments"})
+					return
+				}
+				if paid > 0 {
+					c.JSON(http.StatusConflict, gin.H{"error": "an invoice with payments cannot be set back to PENDING"})
+					return
+				}
+				updateObj = append(updateObj, bson.E{Key: "payment_status", Value: invoice.Payment_status})
+			}
 		}
 
 		status := "PENDING"
@@ -182,7 +307,6 @@ func UpdateInvoice() gin.HandlerFunc {
 		}
 
 		// update mongodb
-		invoiceId := c.Param("invoice_id")
 		filter := bson.M{"invoice_id": invoiceId}
 		result, err := invoiceCollection.UpdateOne(
 			ctx,
@@ -197,9 +321,78 @@ func UpdateInvoice() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		if paying {
+			if err := settleOrder(ctx, existing.Order_id, c.GetString("uid")); err != nil {
+				orderChangeError(c, err)
+				return
+			}
+		}
+
+		publishInvoice(ctx, "invoice.updated", invoiceId)
 
 		// response
 		c.JSON(http.StatusOK, result)
 
 	}
 }
+
+// orderTotals prices an order from its items' snapshotted prices and tax
+// rates less the line and order discounts.
+func orderTotals(ctx context.Context, orderId string) (helper.InvoiceTotals, error) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return helper.InvoiceTotals{}, err
+	}
+
+	result, err := orderItemCollection.Find(ctx, bson.M{"order_id": orderId}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return helper.InvoiceTotals{}, err
+	}
+	var orderItems []models.OrderItem
+	if err = result.All(ctx, &orderItems); err != nil {
+		return helper.InvoiceTotals{}, err
+	}
+
+	return helper.CalculateInvoiceTotals(orderItems, order.Discounts), nil
+}
+
+// invoiceTotals adds the invoice's service charge to its order totals, takes
+// its part of them when the order is split and adds its tips.
+func invoiceTotals(ctx context.Context, invoice models.Invoice) (helper.InvoiceTotals, error) {
+	totals, err := orderTotals(ctx, invoice.Order_id)
+	if err != nil {
+		return totals, err
+	}
+	helper.AddServiceCharge(&totals, invoice.Service_charge)
+
+	if split := invoice.Split; split != nil {
+		var parts []helper.InvoiceTotals
+		if split.Mode == "EVEN" {
+			parts = helper.SplitTotalsEvenly(totals, split.Parts)
+		} else {
+			// the service charge is shared over every part of the order
+			orderItemIds, err := splitOrderItemIds(ctx, *split, split.Parts)
+			if err != nil {
+				return totals, err
+			}
+			parts = helper.SplitTotalsByItems(totals, orderItemIds)
+		}
+		if split.Part < 1 || split.Part > len(parts) {
+			return totals, fmt.Errorf("invoice is part %d of %d", split.Part, len(parts))
+		}
+		totals = parts[split.Part-1]
+	}
+
+	helper.AddTips(&totals, invoice.Tips)
+	return totals, nil
+}
+
+// settleOrder pays the order once all its invoices are paid.
+func settleOrder(ctx context.Context, orderId string, actor string) error {
+	unpaid, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": orderId, "payment_status": bson.M{"$ne": "PAID"}})
+	if err != nil || unpaid > 0 {
+		return err
+	}
+	_, err = transitionOrder(ctx, orderId, "PAID", actor, nil)
+	return err
+}
@@ -0,0 +1,280 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management-backend/models"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func GetKitchenTickets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// all stations unless one is given
+		station := c.Param("station")
+		if station != "" && !models.IsStation(station) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "station was not found"})
+			return
+		}
+
+		// retrieve
+		result, err := orderItemCollection.Aggregate(ctx, kitchenTicketsPipeline(station))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing kitchen tickets"})
+			return
+		}
+
+		// decode
+		tickets := []bson.M{}
+		if err = result.All(ctx, &tickets); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing kitchen tickets"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, tickets)
+	}
+}
+
+func BumpOrderItem() gin.HandlerFunc {
+	bump := kitchenAction("READY")
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// a combo made at several stations is ready once each has bumped it
+		stations, err := pendingComboStations(ctx, c.Param("order_item_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching the order item"})
+			return
+		}
+		if len(stations) > 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("the combo is still being made at %s, bump it from their tickets", strings.Join(stations, ", "))})
+			return
+		}
+		bump(c)
+	}
+}
+
+func RecallOrderItem() gin.HandlerFunc {
+	return kitchenAction("PREPARING")
+}
+
+func BumpKitchenTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		station := c.Param("station")
+		if !models.IsStation(station) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "station was not found"})
+			return
+		}
+
+		// the ticket is the order's items being made at the station
+		orderId := c.Param("order_id")
+		pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: bson.M{"order_id": orderId}}}}
+		pipeline = append(pipeline, kitchenTicketsPipeline(station)...)
+		result, err := orderItemCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching the kitchen ticket"})
+			return
+		}
+		var tickets []kitchenTicket
+		if err = result.All(ctx, &tickets); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while fetching the kitchen ticket"})
+			return
+		}
+		if len(tickets) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "kitchen ticket was not found"})
+			return
+		}
+
+		// bump every item on it; a combo is ready once every station making
+		// part of it has bumped
+		orderItems := []models.OrderItem{}
+		for _, item := range tickets[0].Items {
+			if item.Combo_id != nil && slices.ContainsFunc(item.Pending_stations, func(s string) bool { return s != station }) {
+				orderItem, err := bumpComboStation(ctx, item.Order_item_id, station)
+				if err != nil {
+					orderChangeError(c, err)
+					return
+				}
+				orderItems = append(orderItems, orderItem)
+				continue
+			}
+			orderItem, err := transitionOrderItem(ctx, item.Order_item_id, "READY", c.GetString("uid"), nil)
+			if err != nil {
+				orderChangeError(c, err)
+				return
+			}
+			orderItems = append(orderItems, orderItem)
+		}
+		if err := rollUpOrderStatus(ctx, orderId, c.GetString("uid")); err != nil {
+			log.Println("order status was not rolled up:", orderId, err)
+		}
+
+		// response
+		c.JSON(http.StatusOK, orderItems)
+	}
+}
+
+// kitchenTicket is what bumping needs of a ticket from kitchenTicketsPipeline.
+type kitchenTicket struct {
+	Items []struct {
+		Order_item_id    string   `bson:"order_item_id"`
+		Combo_id         *string  `bson:"combo_id"`
+		Pending_stations []string `bson:"pending_stations"`
+	} `bson:"items"`
+}
+
+// pendingComboStations lists the stations still making part of a combo order
+// item in the kitchen, none for other items.
+func pendingComboStations(ctx context.Context, orderItemId string) ([]string, error) {
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: bson.M{"order_item_id": orderItemId}}}}
+	pipeline = append(pipeline, kitchenTicketsPipeline("")...)
+	result, err := orderItemCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var tickets []kitchenTicket
+	if err = result.All(ctx, &tickets); err != nil {
+		return nil, err
+	}
+	if len(tickets) == 0 || len(tickets[0].Items) == 0 || tickets[0].Items[0].Combo_id == nil {
+		return nil, nil
+	}
+	return tickets[0].Items[0].Pending_stations, nil
+}
+
+// bumpComboStation records that station has made its part of a combo order
+// item that is still being made at other stations.
+func bumpComboStation(ctx context.Context, orderItemId string, station string) (models.OrderItem, error) {
+	var orderItem models.OrderItem
+	updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	err := orderItemCollection.FindOneAndUpdate(ctx,
+		bson.M{"order_item_id": orderItemId, "status": bson.M{"$in": bson.A{"FIRED", "PREPARING"}}},
+		bson.M{
+			"$addToSet": bson.M{"bumped_stations": station},
+			"$set":      bson.M{"updated_at": updated_at},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&orderItem)
+	if err == mongo.ErrNoDocuments {
+		return orderItem, fmt.Errorf("the order item status changed meanwhile, try again")
+	}
+	if err == nil {
+		publishOrderItem(ctx, "order_item.updated", orderItemId)
+	}
+	return orderItem, err
+}
+
+// kitchenAction moves an order item to status from the kitchen display.
+func kitchenAction(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// move the order item, then its order along with it
+		orderItemId := c.Param("order_item_id")
+		orderItem, err := transitionOrderItem(ctx, orderItemId, status, c.GetString("uid"), nil)
+		if err != nil {
+			orderChangeError(c, err)
+			return
+		}
+		if err := rollUpOrderStatus(ctx, orderItem.Order_id, c.GetString("uid")); err != nil {
+			log.Println("order status was not rolled up:", orderItem.Order_id, err)
+		}
+
+		// response
+		c.JSON(http.StatusOK, orderItem)
+	}
+}
+
+// kitchenTicketsPipeline groups the fired order items at a station (or at
+// every station when station is empty) into one ticket per order, oldest
+// first, listing items by course. Held courses are not shown until fired.
+// A combo is on a station's ticket with just its components made there,
+// until that station has bumped it. Ticket age is the seconds since its
+// first item was fired.
+func kitchenTicketsPipeline(station string) mongo.Pipeline {
+	matchStage := bson.D{{Key: "$match", Value: bson.M{"status": bson.M{"$in": bson.A{"FIRED", "PREPARING"}}}}}
+
+	projectStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "_id", Value: 0},
+		{Key: "order_item_id", Value: 1},
+		{Key: "order_id", Value: 1},
+		{Key: "table_number", Value: "$table.table_number"},
+		{Key: "food_name", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$food.name", "$combo.name"}}}},
+		{Key: "station", Value: "$food.station"},
+		{Key: "quantity", Value: 1},
+		{Key: "course", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$course", 1}}}},
+		{Key: "combo_id", Value: 1},
+		{Key: "components", Value: componentsProjection()},
+		{Key: "bumped_stations", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$bumped_stations", bson.A{}}}}},
+		{Key: "status", Value: 1},
+		{Key: "fired_at", Value: bson.D{{Key: "$max", Value: bson.D{{Key: "$map", Value: bson.D{
+			{Key: "input", Value: bson.D{{Key: "$filter", Value: bson.D{
+				{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$status_history", bson.A{}}}}},
+				{Key: "cond", Value: bson.D{{Key: "$eq", Value: bson.A{"$$this.to", "FIRED"}}}},
+			}}}},
+			{Key: "in", Value: "$$this.changed_at"},
+		}}}}}},
+	}}}
+
+	pipeline := mongo.Pipeline{matchStage}
+	pipeline = append(pipeline, orderItemJoinStages()...)
+	// the stations still making part of a combo
+	pendingStage := bson.D{{Key: "$addFields", Value: bson.D{{Key: "pending_stations", Value: bson.D{{Key: "$setDifference", Value: bson.A{
+		"$components.station",
+		bson.D{{Key: "$concatArrays", Value: bson.A{"$bumped_stations", bson.A{nil}}}},
+	}}}}}}}
+
+	pipeline = append(pipeline, projectStage, pendingStage)
+	if station != "" {
+		pipeline = append(pipeline,
+			bson.D{{Key: "$match", Value: bson.M{"$or": bson.A{bson.M{"station": station}, bson.M{"pending_stations": station}}}}},
+			bson.D{{Key: "$addFields", Value: bson.D{{Key: "components", Value: bson.D{{Key: "$filter", Value: bson.D{
+				{Key: "input", Value: "$components"},
+				{Key: "cond", Value: bson.D{{Key: "$eq", Value: bson.A{"$$this.station", station}}}},
+			}}}}}}},
+		)
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "course", Value: 1}, {Key: "fired_at", Value: 1}, {Key: "order_item_id", Value: 1}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$order_id"},
+			{Key: "table_number", Value: bson.D{{Key: "$first", Value: "$table_number"}}},
+			{Key: "fired_at", Value: bson.D{{Key: "$min", Value: "$fired_at"}}},
+			{Key: "items", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "order_id", Value: "$_id"},
+			{Key: "table_number", Value: 1},
+			{Key: "fired_at", Value: 1},
+			{Key: "age_seconds", Value: bson.D{{Key: "$floor", Value: bson.D{{Key: "$divide", Value: bson.A{
+				bson.D{{Key: "$subtract", Value: bson.A{"$$NOW", "$fired_at"}}},
+				1000,
+			}}}}}},
+			{Key: "items", Value: 1},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "fired_at", Value: 1}, {Key: "order_id", Value: 1}}}},
+	)
+	return pipeline
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"restaurant-management-backend/database"
 	"restaurant-management-backend/models"
@@ -16,6 +17,11 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+type MenuView struct {
+	models.Menu
+	Categories []bson.M `json:"categories"`
+}
+
 var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
 
 func GetMenus() gin.HandlerFunc {
@@ -24,10 +30,13 @@ func GetMenus() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		// retrieve and decode
-		result, err := menuCollection.Find(context.TODO(), bson.M{})
+		// retrieve in the requested language and decode
+		result, err := menuCollection.Aggregate(ctx, mongo.Pipeline{
+			localizeStage(c, "name", "category"),
+		})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the menu items"})
+			return
 		}
 		var allMenus []bson.M
 		if err = result.All(ctx, &allMenus); err != nil {
@@ -45,17 +54,117 @@ func GetMenu() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		// retrieve and decode
+		// retrieve in the requested language and decode
 		menuId := c.Param("menu_id")
-		var menu models.Menu
-
-		err := menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu)
+		menu, err := findLocalizedMenu(ctx, c, menuId)
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "error occurred while fetching the menu"})
+			return
+		}
+
+		if c.Query("expand") != "foods" {
+			c.JSON(http.StatusOK, menu)
+			return
+		}
+
+		// expand the menu with its foods grouped by category
+		categories, err := menuFoodsByCategory(ctx, bson.M{"menu_id": menuId}, localizeStage(c, "name", "description"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the menu foods"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, MenuView{Menu: menu, Categories: categories})
+	}
+}
+
+func GetMenuFoods() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context and timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// check the menu exists
+		menuId := c.Param("menu_id")
+		count, err := menuCollection.CountDocuments(ctx, bson.M{"menu_id": menuId})
+		if err != nil || count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "menu was not found"})
+			return
+		}
+
+		// retrieve the menu's foods in display order, categories ordered by
+		// their first food like the nested view
+		filter, err := dietaryFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter["menu_id"] = menuId
+
+		categories, err := menuFoodsByCategory(ctx, filter, localizeStage(c, "name", "description"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the menu foods"})
+			return
+		}
+		foods := []interface{}{}
+		for _, category := range categories {
+			if categoryFoods, ok := category["foods"].(bson.A); ok {
+				foods = append(foods, categoryFoods...)
+			}
+		}
+
+		// response
+		c.JSON(http.StatusOK, foods)
+	}
+}
+
+func GetMenuAllergens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context and timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve the menu in the requested language
+		menuId := c.Param("menu_id")
+		menu, err := findLocalizedMenu(ctx, c, menuId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "menu was not found"})
+			return
+		}
+
+		// filter the menu's foods by allergens and dietary tags
+		filter, err := dietaryFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter["menu_id"] = menuId
+
+		result, err := foodCollection.Aggregate(ctx, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: filter}},
+			localizeStage(c, "name"),
+			bson.D{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "food_id", Value: 1},
+				{Key: "name", Value: 1},
+				{Key: "allergens", Value: 1},
+				{Key: "dietary_tags", Value: 1},
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the menu foods"})
+			return
+		}
+		foods := []bson.M{}
+		if err = result.All(ctx, &foods); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the menu foods"})
+			return
 		}
 
 		// response
-		c.JSON(http.StatusOK, menu)
+		c.JSON(http.StatusOK, gin.H{"menu_id": menu.Menu_id, "name": menu.Name, "foods": foods})
 	}
 }
 
@@ -130,6 +239,13 @@ func UpdateMenu() gin.HandlerFunc {
 		if menu.Category != "" {
 			updateObj = append(updateObj, bson.E{Key: "category", Value: menu.Category})
 		}
+		if menu.Translations != nil {
+			if err := validate.Var(menu.Translations, "dive,keys,language_tag,endkeys"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "translations", Value: menu.Translations})
+		}
 		menu.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: menu.Updated_at})
 
@@ -162,6 +278,68 @@ func UpdateMenu() gin.HandlerFunc {
 	}
 }
 
+// findLocalizedMenu fetches a menu with its name and category in the language
+// the request prefers.
+func findLocalizedMenu(ctx context.Context, c *gin.Context, menuId string) (models.Menu, error) {
+	var menu models.Menu
+	result, err := menuCollection.Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"menu_id": menuId}}},
+		localizeStage(c, "name", "category"),
+	})
+	if err != nil {
+		return menu, err
+	}
+	var menus []models.Menu
+	if err = result.All(ctx, &menus); err != nil {
+		return menu, err
+	}
+	if len(menus) == 0 {
+		return menu, mongo.ErrNoDocuments
+	}
+	return menus[0], nil
+}
+
+// menuFoodsByCategory groups the foods matching filter by category. Foods are
+// ordered by display position and categories by their first food, foods
+// without a position come last.
+func menuFoodsByCategory(ctx context.Context, filter bson.M, localize bson.D) ([]bson.M, error) {
+	result, err := foodCollection.Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: filter}},
+		localize,
+		displayPositionStage(),
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "position", Value: 1}, {Key: "name", Value: 1}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$category"},
+			{Key: "position", Value: bson.D{{Key: "$min", Value: "$position"}}},
+			{Key: "foods", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "position", Value: 1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 0},
+			{Key: "category", Value: "$_id"},
+			{Key: "foods", Value: 1},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{{Key: "foods.position", Value: 0}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	categories := []bson.M{}
+	if err = result.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// displayPositionStage adds a sortable "position" that puts foods without a
+// display position after the positioned ones.
+func displayPositionStage() bson.D {
+	return bson.D{{Key: "$addFields", Value: bson.D{
+		{Key: "position", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$display_position", math.MaxInt32}}}},
+	}}}
+}
+
 func inTimeSpan(start, end, check time.Time) bool {
 	return start.After(time.Now()) && end.After(start)
 }
@@ -2,11 +2,13 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"restaurant-management-backend/database"
 	"restaurant-management-backend/models"
+	"slices"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,6 +18,31 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+type OrderNutrition struct {
+	Order_id string                `json:"order_id"`
+	Items    []OrderItemNutrition  `json:"items"`
+	Total    models.NutritionFacts `json:"total"`
+	Complete bool                  `json:"complete"`
+}
+
+type OrderItemNutrition struct {
+	Order_item_id string                 `json:"order_item_id"`
+	Food_id       string                 `json:"food_id"`
+	Name          *string                `json:"name"`
+	Size          *string                `json:"size"`
+	Nutrition     *models.NutritionFacts `json:"nutrition"`
+}
+
+type OrderTransition struct {
+	Status *string `json:"status" validate:"required"`
+	Reason *string `json:"reason" validate:"omitempty,max=200"`
+}
+
+var (
+	errOrderNotFound     = errors.New("order was not found")
+	errOrderItemNotFound = errors.New("order item was not found")
+)
+
 var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "order")
 
 func GetOrders() gin.HandlerFunc {
@@ -24,8 +51,12 @@ func GetOrders() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		// retrieve
-		result, err := orderCollection.Find(context.TODO(), bson.M{})
+		// retrieve, optionally by status
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		result, err := orderCollection.Find(context.TODO(), filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing order items"})
 		}
@@ -97,6 +128,7 @@ func CreateOrder() gin.HandlerFunc {
 		order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		order.ID = primitive.NewObjectID()
 		order.Order_id = order.ID.Hex()
+		openOrder(&order, c.GetString("uid"))
 
 		// insert
 		result, insertErr := orderCollection.InsertOne(ctx, order)
@@ -106,6 +138,8 @@ func CreateOrder() gin.HandlerFunc {
 			return
 		}
 
+		publishOrder(ctx, "order.created", order.Order_id)
+
 		// response
 		c.JSON(http.StatusOK, result)
 	}
@@ -127,41 +161,40 @@ func UpdateOrder() gin.HandlerFunc {
 			return
 		}
 
+		// closed orders are no longer edited
+		orderId := c.Param("order_id")
+		if err := orderOpenForChanges(ctx, orderId); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+
 		// Initialize update object for MongoDB update
 		var updateObj primitive.D
 
 		// TODO: use go routine
 		// If Table ID is provided, check if the corresponding table exists
 		if order.Table_id != nil {
-			err := menuCollection.FindOne(ctx, bson.M{"tabled_id": order.Table_id}).Decode(&table)
+			err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
 			if err != nil {
 				msg := fmt.Sprintf("Table was not found")
 				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 				return
 			}
-			updateObj = append(updateObj, bson.E{"table", order.Table_id})
+			updateObj = append(updateObj, bson.E{Key: "table_id", Value: order.Table_id})
 		}
 
 		// Update the "updated_at" field with the current time
 		order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		updateObj = append(updateObj, bson.E{"updated_at", order.Updated_at})
 
-		// Set up options for the UpdateOne operation, including upsert
-		upsert := true
-		orderId := c.Param("order_id")
-		filter := bson.M{"order_id": orderId}
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
-		}
-
 		// Perform the update operation on the MongoDB collection
+		filter := bson.M{"order_id": orderId}
 		result, err := orderCollection.UpdateOne(
 			ctx,
 			filter,
 			bson.D{
 				{"$set", updateObj},
 			},
-			&opt,
 		)
 
 		// Handle errors during the update operation
@@ -170,6 +203,12 @@ func UpdateOrder() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": errOrderNotFound.Error()})
+			return
+		}
+
+		publishOrder(ctx, "order.updated", orderId)
 
 		// Respond with the result of the update operation
 		defer cancel()
@@ -177,6 +216,89 @@ func UpdateOrder() gin.HandlerFunc {
 	}
 }
 
+func GetOrderNutrition() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context and timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve the order's items
+		orderId := c.Param("order_id")
+		result, err := orderItemCollection.Find(ctx, bson.M{"order_id": orderId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing order items"})
+			return
+		}
+		var orderItems []models.OrderItem
+		if err = result.All(ctx, &orderItems); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing order items"})
+			return
+		}
+		if len(orderItems) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order has no items"})
+			return
+		}
+
+		// voided items are not served
+		orderItems = slices.DeleteFunc(orderItems, func(orderItem models.OrderItem) bool {
+			return orderItemStatus(orderItem) == "VOIDED"
+		})
+
+		// retrieve the ordered foods, combos count their components
+		var foodIds []string
+		for _, orderItem := range orderItems {
+			foodIds = append(foodIds, orderItemFoodIds(orderItem)...)
+		}
+		result, err = foodCollection.Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing foods"})
+			return
+		}
+		var foods []models.Food
+		if err = result.All(ctx, &foods); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing foods"})
+			return
+		}
+		foodsById := map[string]models.Food{}
+		for _, food := range foods {
+			foodsById[food.Food_id] = food
+		}
+
+		// sum the facts of each item in its size
+		summary := OrderNutrition{Order_id: orderId, Items: []OrderItemNutrition{}, Complete: true}
+		for _, orderItem := range orderItems {
+			for _, foodId := range orderItemFoodIds(orderItem) {
+				food, ok := foodsById[foodId]
+				item := OrderItemNutrition{Order_item_id: orderItem.Order_item_id, Food_id: foodId, Size: orderItem.Quantity}
+				if ok {
+					item.Name = food.Name
+					item.Nutrition = nutritionFor(food, orderItem.Quantity)
+				}
+				if item.Nutrition == nil {
+					summary.Complete = false
+				} else {
+					addNutrition(&summary.Total, *item.Nutrition)
+				}
+				summary.Items = append(summary.Items, item)
+			}
+		}
+
+		// response
+		c.JSON(http.StatusOK, summary)
+	}
+}
+
+func addNutrition(total *models.NutritionFacts, facts models.NutritionFacts) {
+	total.Calories = toFixed(total.Calories+facts.Calories, 2)
+	total.Protein_g = toFixed(total.Protein_g+facts.Protein_g, 2)
+	total.Carbohydrates_g = toFixed(total.Carbohydrates_g+facts.Carbohydrates_g, 2)
+	total.Sugar_g = toFixed(total.Sugar_g+facts.Sugar_g, 2)
+	total.Fat_g = toFixed(total.Fat_g+facts.Fat_g, 2)
+	total.Saturated_fat_g = toFixed(total.Saturated_fat_g+facts.Saturated_fat_g, 2)
+	total.Fiber_g = toFixed(total.Fiber_g+facts.Fiber_g, 2)
+	total.Sodium_mg = toFixed(total.Sodium_mg+facts.Sodium_mg, 2)
+}
+
 func OrderItemOrderCreator(order models.Order) string {
 	// context and timeout
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
@@ -193,3 +315,167 @@ func OrderItemOrderCreator(order models.Order) string {
 
 	return order.Order_id
 }
+
+func TransitionOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context and timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var transition OrderTransition
+		if err := c.BindJSON(&transition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(transition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, ok := models.OrderTransitions[*transition.Status]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown order status: %s", *transition.Status)})
+			return
+		}
+		// an order is billed by invoicing it and paid by paying its invoices
+		if *transition.Status == "BILLED" || *transition.Status == "PAID" {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("an order becomes %s through its invoices, not a transition", *transition.Status)})
+			return
+		}
+
+		// move the order; one with payments is not reopened from BILLED, and
+		// reopening one discards its invoices, which no longer match it
+		orderId := c.Param("order_id")
+		var invoiceIds []string
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err == nil && orderStatus(order) == "BILLED" {
+			if err := orderHasNoPayments(ctx, orderId); err != nil {
+				orderChangeError(c, err)
+				return
+			}
+			if invoiceIds, err = orderInvoiceIds(ctx, orderId); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the order's invoices"})
+				return
+			}
+			if err := invoicesHaveNoTips(ctx, invoiceIds); err != nil {
+				orderChangeError(c, err)
+				return
+			}
+		}
+		order, err := transitionOrder(ctx, orderId, *transition.Status, c.GetString("uid"), transition.Reason)
+		if err == errOrderNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if len(invoiceIds) > 0 {
+			for _, invoiceId := range invoiceIds {
+				publishInvoice(ctx, "invoice.deleted", invoiceId)
+			}
+			if _, err := invoiceCollection.DeleteMany(ctx, bson.M{"invoice_id": bson.M{"$in": invoiceIds}}); err != nil {
+				log.Println("invoices of the reopened order were not discarded:", orderId, err)
+			}
+		}
+
+		// response
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// openOrder starts a new order in the OPEN status.
+func openOrder(order *models.Order, actor string) {
+	status := "OPEN"
+	change := models.OrderStatusChange{To: status, Changed_by: actor}
+	change.Changed_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	order.Status = &status
+	order.Status_history = []models.OrderStatusChange{change}
+	order.Discounts = []models.Discount{}
+}
+
+// orderStatus is the order's status; orders from before statuses were kept are open.
+func orderStatus(order models.Order) string {
+	if order.Status == nil {
+		return "OPEN"
+	}
+	return *order.Status
+}
+
+// transitionOrder moves an order to status if its current status allows it,
+// recording the actor. The update only applies while the order is still in
+// the status it was read in, so concurrent transitions cannot both succeed.
+func transitionOrder(ctx context.Context, orderId string, status string, actor string, reason *string) (models.Order, error) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return order, errOrderNotFound
+	}
+	from := orderStatus(order)
+	if !models.CanTransitionOrder(from, status) {
+		return order, fmt.Errorf("an order cannot go from %s to %s", from, status)
+	}
+
+	change := models.OrderStatusChange{From: &from, To: status, Reason: reason, Changed_by: actor}
+	change.Changed_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	err := orderCollection.FindOneAndUpdate(ctx,
+		bson.M{"order_id": orderId, "status": order.Status},
+		bson.M{
+			"$set":  bson.M{"status": status, "updated_at": change.Changed_at},
+			"$push": bson.M{"status_history": change},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return order, fmt.Errorf("the order status changed meanwhile, try again")
+	}
+	if err == nil {
+		publishOrder(ctx, "order.status_changed", orderId)
+	}
+	return order, err
+}
+
+// orderAcceptingItems returns the order if items may be added to or changed on it.
+func orderAcceptingItems(ctx context.Context, orderId string) (models.Order, error) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return order, errOrderNotFound
+	}
+	if status := orderStatus(order); !models.OrderAcceptsItems(status) {
+		return order, fmt.Errorf("items cannot be changed on a %s order", status)
+	}
+	return order, nil
+}
+
+// orderOpenForPriceChanges returns an error when the order is paid or
+// cancelled, or when payments were taken on it: what its invoices are paying
+// must not change under them.
+func orderOpenForPriceChanges(ctx context.Context, orderId string) error {
+	if err := orderOpenForChanges(ctx, orderId); err != nil {
+		return err
+	}
+	return orderHasNoPayments(ctx, orderId)
+}
+
+// orderHasNoPayments returns an error when payments were taken on the order.
+func orderHasNoPayments(ctx context.Context, orderId string) error {
+	payments, err := paymentCollection.CountDocuments(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return err
+	}
+	if payments > 0 {
+		return fmt.Errorf("payments were taken on the order, its charges cannot change")
+	}
+	return nil
+}
+
+// orderOpenForChanges returns an error when the order is paid or cancelled.
+func orderOpenForChanges(ctx context.Context, orderId string) error {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return errOrderNotFound
+	}
+	if status := orderStatus(order); models.OrderIsClosed(status) {
+		return fmt.Errorf("a %s order cannot be changed", status)
+	}
+	return nil
+}
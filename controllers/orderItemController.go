@@ -2,10 +2,12 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"restaurant-management-backend/database"
 	"restaurant-management-backend/models"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,8 +17,11 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// OrderItemPack adds items to the order Order_id, or to a new order for
+// Table_id when no order is given.
 type OrderItemPack struct {
 	Table_id    *string
+	Order_id    *string
 	Order_items []models.OrderItem
 }
 
@@ -57,7 +62,7 @@ func GetOrderItem() gin.HandlerFunc {
 		orderItemId := c.Param("order_item_id")
 		var orderItem models.OrderItem
 
-		err := orderItemCollection.FindOne(ctx, bson.M{"orderItem_id": orderItemId}).Decode(&orderItem)
+		err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing ordered item"})
 			return
@@ -82,16 +87,57 @@ func CreateOrderItem() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		// bind order
-		order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		order_id := OrderItemOrderCreator(order)
-		order.Table_id = orderItemPack.Table_id
+		// bind order, items are only added to orders that accept them
+		var order_id string
+		if orderItemPack.Order_id != nil {
+			existing, err := orderAcceptingItems(ctx, *orderItemPack.Order_id)
+			if err != nil {
+				orderChangeError(c, err)
+				return
+			}
+			order_id = existing.Order_id
+		} else {
+			order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			order.Table_id = orderItemPack.Table_id
+			openOrder(&order, c.GetString("uid"))
+			order_id = OrderItemOrderCreator(order)
+		}
 
 		// bind orderItems
 		orderItemsToBeInserted := []interface{}{}
+		orderItems := []models.OrderItem{}
 		for _, orderItem := range orderItemPack.Order_items {
 			orderItem.Order_id = order_id
 
+			// prices, discounts and adjustments are only set by the server
+			// and their own endpoints, never by the client
+			orderItem.Base_price = nil
+			orderItem.Pricing_rule = nil
+			orderItem.Discount = nil
+			orderItem.Adjustment = nil
+			orderItem.Bumped_stations = nil
+
+			// combos are priced as a bundle from their chosen components
+			if orderItem.Combo_id != nil {
+				if err := resolveComboOrderItem(ctx, &orderItem); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			} else if len(orderItem.Components) > 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "components can only be chosen for combos"})
+				return
+			} else if orderItem.Food_id != nil {
+				// the price in effect now is kept on the line
+				if err := snapshotFoodPrice(ctx, &orderItem); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			if err := snapshotTaxes(ctx, &orderItem); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while looking up tax rates"})
+				return
+			}
+
 			validationErr := validate.Struct(orderItem)
 
 			if validationErr != nil {
@@ -102,9 +148,21 @@ func CreateOrderItem() gin.HandlerFunc {
 			orderItem.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 			orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 			orderItem.Order_item_id = orderItem.ID.Hex()
+			// later courses are held until the waiter fires them
+			if orderItem.Course == nil {
+				course := 1
+				orderItem.Course = &course
+			}
+			status := "PENDING"
+			if *orderItem.Course > 1 {
+				status = "HELD"
+			}
+			orderItem.Status = &status
+			orderItem.Status_history = []models.OrderStatusChange{{To: status, Changed_by: c.GetString("uid"), Changed_at: orderItem.Created_at}}
 			var num = toFixed(*orderItem.Unit_price, 2)
 			orderItem.Unit_price = &num
 			orderItemsToBeInserted = append(orderItemsToBeInserted, orderItem)
+			orderItems = append(orderItems, orderItem)
 		}
 
 		// insert
@@ -113,6 +171,18 @@ func CreateOrderItem() gin.HandlerFunc {
 			log.Fatal(err)
 		}
 
+		// the order is placed, a stock error must not fail it
+		if err := DepleteStock(ctx, orderItems, c.GetString("uid")); err != nil {
+			log.Println("stock depletion failed:", err)
+		}
+
+		if orderItemPack.Order_id == nil {
+			publishOrder(ctx, "order.created", order_id)
+		}
+		for _, orderItem := range orderItems {
+			publishOrderItem(ctx, "order_item.created", orderItem.Order_item_id)
+		}
+
 		// response
 		c.JSON(http.StatusOK, insertedOrderItems)
 	}
@@ -131,42 +201,125 @@ func UpdateOrderItem() gin.HandlerFunc {
 			return
 		}
 
+		// items of billed, paid or cancelled orders stay as they are
+		orderItemId := c.Param("order_item_id")
+		var existing models.OrderItem
+		if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&existing); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": errOrderItemNotFound.Error()})
+			return
+		}
+		if _, err := orderAcceptingItems(ctx, existing.Order_id); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+		// once fired the kitchen is making what was ordered, only the seat can move
+		changesDish := orderItem.Quantity != nil || orderItem.Food_id != nil || orderItem.Course != nil
+		if status := orderItemStatus(existing); changesDish && !models.OrderItemEditable(status) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("a %s order item cannot be changed", status)})
+			return
+		}
+
 		// prepare update obj
 		var updateObj primitive.D
 
-		if orderItem.Unit_price != nil {
-			updateObj = append(updateObj, bson.E{"unit_price", *&orderItem.Unit_price})
-		}
+		// the unit price is the snapshot taken when the food was ordered
 		if orderItem.Quantity != nil {
 			updateObj = append(updateObj, bson.E{"quantity", *orderItem.Quantity})
 		}
+		if orderItem.Seat != nil {
+			if *orderItem.Seat < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "seat must be at least 1"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "seat", Value: orderItem.Seat})
+		}
+		if orderItem.Course != nil {
+			if *orderItem.Course < 1 || *orderItem.Course > 10 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "course must be between 1 and 10"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "course", Value: orderItem.Course})
+		}
+		// a later course is held until fired, like a new item
+		var statusChange *models.OrderStatusChange
+		if orderItem.Course != nil {
+			from := orderItemStatus(existing)
+			status := "PENDING"
+			if *orderItem.Course > 1 {
+				status = "HELD"
+			}
+			if status != from {
+				reason := fmt.Sprintf("moved to course %d", *orderItem.Course)
+				statusChange = &models.OrderStatusChange{From: &from, To: status, Reason: &reason, Changed_by: c.GetString("uid")}
+				updateObj = append(updateObj, bson.E{Key: "status", Value: status})
+			}
+		}
 		if orderItem.Food_id != nil {
+			if err := snapshotFoodPrice(ctx, &orderItem); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			// a combo line switched to a single food stops being a combo
 			updateObj = append(updateObj, bson.E{"food_id", *orderItem.Food_id})
+			updateObj = append(updateObj, bson.E{Key: "combo_id", Value: nil})
+			updateObj = append(updateObj, bson.E{Key: "components", Value: nil})
+			updateObj = append(updateObj, bson.E{Key: "unit_price", Value: orderItem.Unit_price})
+			updateObj = append(updateObj, bson.E{Key: "base_price", Value: orderItem.Base_price})
+			updateObj = append(updateObj, bson.E{Key: "pricing_rule", Value: orderItem.Pricing_rule})
+			if err := snapshotTaxes(ctx, &orderItem); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while looking up tax rates"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "tax_category", Value: orderItem.Tax_category})
+			updateObj = append(updateObj, bson.E{Key: "taxes", Value: orderItem.Taxes})
 		}
 		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		updateObj = append(updateObj, bson.E{"updated_at", orderItem.Updated_at})
-
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		update := bson.D{
+			{"$set", updateObj},
+		}
+		if statusChange != nil {
+			statusChange.Changed_at = orderItem.Updated_at
+			update = append(update, bson.E{Key: "$push", Value: bson.M{"status_history": statusChange}})
 		}
 
-		// update mongodb
-		orderItemId := c.Param("order_item_id")
-		filter := bson.M{"order_item_id": orderItemId}
+		// update mongodb, unless the item was fired meanwhile
+		filter := bson.M{"order_item_id": orderItemId, "status": existing.Status}
 		result, err := orderItemCollection.UpdateOne(
 			ctx,
 			filter,
-			bson.D{
-				{"$set", updateObj},
-			},
-			&opt,
+			update,
 		)
 		if err != nil {
 			msg := "Order item update failed"
 			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "the order item status changed meanwhile, try again"})
+			return
+		}
+
+		// another dish or size uses other ingredients: put back what the old
+		// one took and take what the new one needs
+		if orderItem.Food_id != nil || orderItem.Quantity != nil {
+			changed := existing
+			if orderItem.Food_id != nil {
+				changed.Food_id = orderItem.Food_id
+				changed.Combo_id = nil
+				changed.Components = nil
+			}
+			if orderItem.Quantity != nil {
+				changed.Quantity = orderItem.Quantity
+			}
+			if err := RestoreStock(ctx, []models.OrderItem{existing}, c.GetString("uid")); err != nil {
+				log.Println("stock was not restored for the order item:", orderItemId, err)
+			} else if err := DepleteStock(ctx, []models.OrderItem{changed}, c.GetString("uid")); err != nil {
+				log.Println("stock was not depleted for the order item:", orderItemId, err)
+			}
+		}
+
+		publishOrderItem(ctx, "order_item.updated", orderItemId)
 
 		// response
 		c.JSON(http.StatusOK, result)
@@ -175,6 +328,10 @@ func UpdateOrderItem() gin.HandlerFunc {
 
 func GetOrderItemsByOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
 		// retrieve and decode
 		orderId := c.Param("order_id")
 		allOrderItems, err := ItemsByOrder(orderId)
@@ -183,6 +340,16 @@ func GetOrderItemsByOrder() gin.HandlerFunc {
 			return
 		}
 
+		// what is due is the order's total as invoiced, with discounts and tax
+		if len(allOrderItems) > 0 {
+			totals, err := orderTotals(ctx, orderId)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while calculating the order totals"})
+				return
+			}
+			allOrderItems[0]["payment_due"] = totals.Total
+		}
+
 		// response
 		c.JSON(http.StatusOK, allOrderItems)
 	}
@@ -193,26 +360,30 @@ func ItemsByOrder(id string) (OrderItems []primitive.M, err error) {
 	defer cancel()
 
 	matchStage := bson.D{{"$match", bson.D{{"order_id", id}}}}
-	lookupStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
-	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	lookupOrderStage := bson.D{{"$lookup", bson.D{{"from", "order"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "order"}}}}
-	unwindOrderStage := bson.D{{"$unwind", bson.D{{"path", "$order"}, {"preserveNullAndEmptyArrays", true}}}}
-
-	lookupTableStage := bson.D{{"$lookup", bson.D{{"from", "table"}, {"localField", "order.table_id"}, {"foreignField", "table_id"}, {"as", "table"}}}}
-	unwindTableStage := bson.D{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}}
 
 	projectStage := bson.D{
 		{"$project", bson.D{
 			{"id", 0},
-			{"amount", "$food.price"},
+			{"amount", "$unit_price"},
 			{"total_count", 1},
-			{"food_name", "$food.name"},
+			{"food_name", bson.D{{Key: "$ifNull", Value: bson.A{"$food.name", "$combo.name"}}}},
+			{"combo_id", 1},
+			{"components", componentsProjection()},
 			{"food_image", "$food.food_image"},
 			{"table_number", "$table.table_number"},
 			{"table_id", "$table.table_id"},
 			{"order_id", "$order.order_id"},
-			{"price", "$food.price"},
+			{"price", "$unit_price"},
+			{"base_price", 1},
+			{"pricing_rule", 1},
+			{"discount", 1},
+			{"adjustment", 1},
+			{"course", 1},
+			{"seat", 1},
+			{"status", 1},
+			{"tax_category", 1},
+			{"taxes", 1},
+			{"order_item_id", 1},
 			{"quantity", 1},
 		}}}
 
@@ -224,7 +395,6 @@ func ItemsByOrder(id string) (OrderItems []primitive.M, err error) {
 					{"order_id", "$order_id"},
 					{"table_id", "$table_id"},
 					{"table_number", "$table_number"}}},
-				{"payment_due", bson.D{{"$sum", "$amount"}}},
 				{"total_count", bson.D{{"$sum", 1}}},
 				{"order_items", bson.D{{"$push", "$$ROOT"}}},
 			},
@@ -234,31 +404,312 @@ func ItemsByOrder(id string) (OrderItems []primitive.M, err error) {
 		{"$project", bson.D{
 
 			{"id", 0},
-			{"payment_due", 1},
 			{"total_count", 1},
 			{"table_number", "$_id.table_number"},
 			{"order_items", 1},
 		}}}
 
-	result, err := orderItemCollection.Aggregate(ctx, mongo.Pipeline{
-		matchStage,
+	pipeline := mongo.Pipeline{matchStage}
+	pipeline = append(pipeline, orderItemJoinStages()...)
+	pipeline = append(pipeline, projectStage, groupStage, projectStage2)
+	result, err := orderItemCollection.Aggregate(ctx, pipeline)
+
+	if err != nil {
+		panic(err)
+	}
+
+	if err = result.All(ctx, &OrderItems); err != nil {
+		panic(err)
+	}
+
+	return OrderItems, err
+}
+
+// orderItemJoinStages look up an order item's food, combo and component
+// foods, and its order and table.
+func orderItemJoinStages() mongo.Pipeline {
+	lookupStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
+	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
+
+	lookupComboStage := bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "combo"}, {Key: "localField", Value: "combo_id"}, {Key: "foreignField", Value: "combo_id"}, {Key: "as", Value: "combo"}}}}
+	unwindComboStage := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$combo"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}}
+	lookupComponentsStage := bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "food"}, {Key: "localField", Value: "components.food_id"}, {Key: "foreignField", Value: "food_id"}, {Key: "as", Value: "component_foods"}}}}
+
+	lookupOrderStage := bson.D{{"$lookup", bson.D{{"from", "order"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "order"}}}}
+	unwindOrderStage := bson.D{{"$unwind", bson.D{{"path", "$order"}, {"preserveNullAndEmptyArrays", true}}}}
+
+	lookupTableStage := bson.D{{"$lookup", bson.D{{"from", "table"}, {"localField", "order.table_id"}, {"foreignField", "table_id"}, {"as", "table"}}}}
+	unwindTableStage := bson.D{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}}
+
+	return mongo.Pipeline{
 		lookupStage,
 		unwindStage,
+		lookupComboStage,
+		unwindComboStage,
+		lookupComponentsStage,
 		lookupOrderStage,
 		unwindOrderStage,
 		lookupTableStage,
 		unwindTableStage,
-		projectStage,
-		groupStage,
-		projectStage2})
+	}
+}
+
+// componentsProjection breaks a combo line out into its chosen components,
+// naming each from the looked up component foods.
+func componentsProjection() bson.D {
+	return bson.D{{Key: "$map", Value: bson.D{
+		{Key: "input", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$components", bson.A{}}}}},
+		{Key: "as", Value: "component"},
+		{Key: "in", Value: bson.D{
+			{Key: "slot", Value: "$$component.slot"},
+			{Key: "food_id", Value: "$$component.food_id"},
+			{Key: "food_name", Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{
+				"$component_foods.name",
+				bson.D{{Key: "$indexOfArray", Value: bson.A{"$component_foods.food_id", "$$component.food_id"}}},
+			}}}},
+			{Key: "station", Value: bson.D{{Key: "$let", Value: bson.D{
+				{Key: "vars", Value: bson.D{{Key: "food", Value: bson.D{{Key: "$arrayElemAt", Value: bson.A{
+					"$component_foods",
+					bson.D{{Key: "$indexOfArray", Value: bson.A{"$component_foods.food_id", "$$component.food_id"}}},
+				}}}}}},
+				{Key: "in", Value: "$$food.station"},
+			}}}},
+		}},
+	}}}
+}
 
+// orderItemFoodIds lists the foods an order item serves: its food, or the
+// chosen components of a combo.
+func orderItemFoodIds(orderItem models.OrderItem) []string {
+	var foodIds []string
+	if orderItem.Food_id != nil {
+		foodIds = append(foodIds, *orderItem.Food_id)
+	}
+	for _, component := range orderItem.Components {
+		foodIds = append(foodIds, component.Food_id)
+	}
+	return foodIds
+}
+
+// snapshotFoodPrice sets the unit price of a food order item to the food's
+// price in effect now after pricing rules, rejecting unknown and unavailable foods.
+func snapshotFoodPrice(ctx context.Context, orderItem *models.OrderItem) error {
+	var food models.Food
+	if err := foodCollection.FindOne(ctx, bson.M{"food_id": orderItem.Food_id}).Decode(&food); err != nil {
+		return fmt.Errorf("food was not found: %s", *orderItem.Food_id)
+	}
+	if food.Is_available != nil && !*food.Is_available {
+		return fmt.Errorf("food is not available: %s", *orderItem.Food_id)
+	}
+
+	now := time.Now()
+	price, err := priceInEffect(ctx, food, now)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	price = toFixed(price, 2)
+	orderItem.Unit_price = &price
+	orderItem.Tax_category = food.Tax_category
+	return applyPricingRules(ctx, orderItem, food, now)
+}
 
-	if err = result.All(ctx, &OrderItems); err != nil {
-		panic(err)
+func TransitionOrderItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var transition OrderTransition
+		if err := c.BindJSON(&transition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(transition); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, ok := models.OrderItemTransitions[*transition.Status]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown order item status: %s", *transition.Status)})
+			return
+		}
+		if *transition.Status == "VOIDED" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "void order items with a reason code at /orderItems/:order_item_id/void"})
+			return
+		}
+
+		// move the order item, then its order along with it
+		orderItemId := c.Param("order_item_id")
+		orderItem, err := transitionOrderItem(ctx, orderItemId, *transition.Status, c.GetString("uid"), transition.Reason)
+		if err != nil {
+			orderChangeError(c, err)
+			return
+		}
+		if err := rollUpOrderStatus(ctx, orderItem.Order_id, c.GetString("uid")); err != nil {
+			log.Println("order status was not rolled up:", orderItem.Order_id, err)
+		}
+
+		// response
+		c.JSON(http.StatusOK, orderItem)
 	}
+}
 
-	return OrderItems, err
+// orderItemStatus is the order item's status; items from before statuses
+// were kept are pending.
+func orderItemStatus(orderItem models.OrderItem) string {
+	if orderItem.Status == nil {
+		return "PENDING"
+	}
+	return *orderItem.Status
+}
+
+// transitionOrderItem moves an order item to status if its current status
+// allows it, recording the actor. Like orders, the update only applies while
+// the item is still in the status it was read in.
+func transitionOrderItem(ctx context.Context, orderItemId string, status string, actor string, reason *string) (models.OrderItem, error) {
+	var orderItem models.OrderItem
+	if err := orderItemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem); err != nil {
+		return orderItem, errOrderItemNotFound
+	}
+	if err := orderOpenForChanges(ctx, orderItem.Order_id); err != nil {
+		return orderItem, err
+	}
+	from := orderItemStatus(orderItem)
+	if !models.CanTransitionOrderItem(from, status) {
+		return orderItem, fmt.Errorf("an order item cannot go from %s to %s", from, status)
+	}
+
+	change := models.OrderStatusChange{From: &from, To: status, Reason: reason, Changed_by: actor}
+	change.Changed_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	update := bson.M{
+		"$set":  bson.M{"status": status, "updated_at": change.Changed_at},
+		"$push": bson.M{"status_history": change},
+	}
+	if from == "READY" {
+		// a recalled combo is made again at every station
+		update["$unset"] = bson.M{"bumped_stations": ""}
+	}
+	err := orderItemCollection.FindOneAndUpdate(ctx,
+		bson.M{"order_item_id": orderItemId, "status": orderItem.Status},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&orderItem)
+	if err == mongo.ErrNoDocuments {
+		return orderItem, fmt.Errorf("the order item status changed meanwhile, try again")
+	}
+	if err == nil {
+		publishOrderItem(ctx, "order_item.status_changed", orderItemId)
+	}
+	return orderItem, err
+}
+
+// rollUpOrderStatus moves an order along with its items: it is sent to the
+// kitchen once any item is, and served once every item that was not voided
+// has been served.
+func rollUpOrderStatus(ctx context.Context, orderId string, actor string) error {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return errOrderNotFound
+	}
+	result, err := orderItemCollection.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return err
+	}
+	var orderItems []models.OrderItem
+	if err = result.All(ctx, &orderItems); err != nil {
+		return err
+	}
+
+	inKitchen, served, open := false, 0, 0
+	for _, orderItem := range orderItems {
+		switch status := orderItemStatus(orderItem); {
+		case models.OrderItemInKitchen(status):
+			inKitchen = true
+			open++
+		case status == "SERVED":
+			served++
+		case models.OrderItemEditable(status):
+			open++
+		}
+	}
+
+	status := orderStatus(order)
+	reason := "order items rolled up"
+	switch {
+	case inKitchen && (status == "OPEN" || status == "SERVED"):
+		_, err = transitionOrder(ctx, orderId, "SENT_TO_KITCHEN", actor, &reason)
+	case !inKitchen && open == 0 && served > 0 && status == "SENT_TO_KITCHEN":
+		_, err = transitionOrder(ctx, orderId, "SERVED", actor, &reason)
+	}
+	return err
+}
+
+func FireCourse() gin.HandlerFunc {
+	return courseAction("FIRED")
+}
+
+func HoldCourse() gin.HandlerFunc {
+	return courseAction("HELD")
+}
+
+// courseAction moves the order's items of a course that have not been fired
+// yet to status, then moves the order along with them.
+func courseAction(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderId := c.Param("order_id")
+		course, err := strconv.Atoi(c.Param("course"))
+		if err != nil || course < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "course must be a positive number"})
+			return
+		}
+
+		// retrieve the course's items that can move, items without a course are in the first
+		courseFilter := bson.M{"course": course}
+		if course == 1 {
+			courseFilter = bson.M{"$or": bson.A{bson.M{"course": 1}, bson.M{"course": nil}}}
+		}
+		statuses := bson.A{"HELD", "PENDING", nil}
+		if status == "HELD" {
+			statuses = bson.A{"PENDING", nil}
+		}
+		result, err := orderItemCollection.Find(ctx, bson.M{
+			"order_id": orderId,
+			"status":   bson.M{"$in": statuses},
+			"$and":     bson.A{courseFilter},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the course's items"})
+			return
+		}
+		var courseItems []models.OrderItem
+		if err = result.All(ctx, &courseItems); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the course's items"})
+			return
+		}
+		if len(courseItems) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("course %d has no items to move to %s", course, status)})
+			return
+		}
+
+		// move them
+		orderItems := []models.OrderItem{}
+		for _, courseItem := range courseItems {
+			orderItem, err := transitionOrderItem(ctx, courseItem.Order_item_id, status, c.GetString("uid"), nil)
+			if err != nil {
+				orderChangeError(c, err)
+				return
+			}
+			orderItems = append(orderItems, orderItem)
+		}
+		if err := rollUpOrderStatus(ctx, orderId, c.GetString("uid")); err != nil {
+			log.Println("order status was not rolled up:", orderId, err)
+		}
+
+		// response
+		c.JSON(http.StatusOK, orderItems)
+	}
 }
@@ -0,0 +1,358 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/helper"
+	"restaurant-management-backend/models"
+	"restaurant-management-backend/payments"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var paymentCollection *mongo.Collection = database.OpenCollection(database.Client, "payment")
+
+// paymentGateway takes card payments; it is nil until configured and when
+// no gateway is.
+var paymentGateway payments.PaymentGateway
+
+var (
+	errInvoiceNotFound = errors.New("invoice was not found")
+	errInvoicePaid     = errors.New("invoice is already paid")
+	errNoGateway       = errors.New("no payment gateway is configured, card payments cannot be taken")
+)
+
+// ConfigurePaymentGateway sets up the payment gateway from the environment.
+func ConfigurePaymentGateway() error {
+	gateway, err := payments.FromEnv()
+	if err != nil {
+		return err
+	}
+	paymentGateway = gateway
+	if gateway == nil {
+		log.Println("no PAYMENT_GATEWAY is configured, card payments are disabled")
+	}
+	return nil
+}
+
+func AddPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var payment models.Payment
+		if err := c.BindJSON(&payment); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(payment); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// a retried card payment is answered with the payment it already made
+		if *payment.Tender == "CARD" {
+			idempotencyKey := c.GetHeader("Idempotency-Key")
+			if idempotencyKey == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "card payments need an Idempotency-Key header"})
+				return
+			}
+			payment.Idempotency_key = &idempotencyKey
+			var existing models.Payment
+			err := paymentCollection.FindOne(ctx, bson.M{"invoice_id": c.Param("invoice_id"), "idempotency_key": idempotencyKey}).Decode(&existing)
+			if err == nil {
+				c.JSON(http.StatusOK, existing)
+				return
+			}
+			if err != mongo.ErrNoDocuments {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the invoice's payments"})
+				return
+			}
+		}
+
+		// only invoices of billed orders are paid
+		invoice, err := payableInvoice(ctx, c.Param("invoice_id"))
+		if err != nil {
+			orderChangeError(c, err)
+			return
+		}
+
+		// record
+		if err := recordPayment(ctx, invoice, &payment, c.GetString("uid")); err != nil {
+			paymentError(c, err)
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, payment)
+	}
+}
+
+func GetInvoicePayments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve
+		var invoice models.Invoice
+		if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": c.Param("invoice_id")}).Decode(&invoice); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": errInvoiceNotFound.Error()})
+			return
+		}
+		payments, err := invoicePayments(ctx, invoice.Invoice_id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the invoice's payments"})
+			return
+		}
+		due, paid, err := invoiceBalance(ctx, invoice)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while calculating the invoice totals"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, gin.H{
+			"invoice_id":     invoice.Invoice_id,
+			"payment_status": invoice.Payment_status,
+			"amount_due":     helper.FromCents(due),
+			"amount_paid":    helper.FromCents(paid),
+			"balance_due":    helper.FromCents(max(due-paid, 0)),
+			"payments":       payments,
+		})
+	}
+}
+
+// invoiceOpenForCharges returns an error when the invoice is paid or, when
+// the change would lower what it owes, has payments already.
+func invoiceOpenForCharges(ctx context.Context, invoiceId string, lowers bool) error {
+	var invoice models.Invoice
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		return errInvoiceNotFound
+	}
+	if invoice.Payment_status != nil && *invoice.Payment_status == "PAID" {
+		return errInvoicePaid
+	}
+	if lowers {
+		paid, err := invoicePaid(ctx, invoiceId)
+		if err != nil {
+			return err
+		}
+		if paid > 0 {
+			return fmt.Errorf("payments were taken on the invoice, what it owes cannot be lowered")
+		}
+	}
+	return nil
+}
+
+// payableInvoice is the invoice, when it is still to be paid and its order
+// has been billed.
+func payableInvoice(ctx context.Context, invoiceId string) (models.Invoice, error) {
+	var invoice models.Invoice
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		return invoice, errInvoiceNotFound
+	}
+	if invoice.Payment_status != nil && *invoice.Payment_status == "PAID" {
+		return invoice, errInvoicePaid
+	}
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err != nil {
+		return invoice, errOrderNotFound
+	}
+	if status := orderStatus(order); status != "BILLED" {
+		return invoice, fmt.Errorf("an invoice of a %s order cannot be paid", status)
+	}
+	return invoice, nil
+}
+
+// recordPayment pays part or all of the invoice's balance, charging cards
+// through the payment gateway first. The invoice is PARTIALLY_PAID until its
+// payments cover the balance and its order is paid once all of its invoices are.
+func recordPayment(ctx context.Context, invoice models.Invoice, payment *models.Payment, actor string) error {
+	due, paid, err := invoiceBalance(ctx, invoice)
+	if err != nil {
+		return err
+	}
+	amount := helper.ToCents(*payment.Amount)
+	if due-paid <= 0 {
+		return errInvoicePaid
+	}
+	if amount > due-paid {
+		return fmt.Errorf("payment of %.2f is more than the balance of %.2f", helper.FromCents(amount), helper.FromCents(due-paid))
+	}
+
+	// cash handed over beyond the payment is given back as change
+	if *payment.Tender == "CASH" {
+		tendered := amount
+		if payment.Tendered != nil {
+			tendered = helper.ToCents(*payment.Tendered)
+		}
+		if tendered < amount {
+			return fmt.Errorf("cash tendered of %.2f is less than the payment of %.2f", helper.FromCents(tendered), helper.FromCents(amount))
+		}
+		tenderedAmount := helper.FromCents(tendered)
+		payment.Tendered = &tenderedAmount
+		payment.Change = helper.FromCents(tendered - amount)
+	} else {
+		payment.Tendered = nil
+		payment.Change = 0
+	}
+
+	// only the gateway sets a card payment's reference, other tenders have none
+	payment.Gateway = ""
+	payment.Reference = nil
+	if *payment.Tender != "CARD" {
+		payment.Idempotency_key = nil
+	}
+
+	payment.ID = primitive.NewObjectID()
+	payment.Payment_id = payment.ID.Hex()
+	if *payment.Tender == "CARD" {
+		reference, err := chargeCard(ctx, payment.Card_token, amount, *payment.Idempotency_key)
+		if err != nil {
+			return err
+		}
+		payment.Reference = &reference
+		payment.Gateway = paymentGateway.Name()
+		payment.Card_token = ""
+	}
+
+	paymentAmount := helper.FromCents(amount)
+	payment.Amount = &paymentAmount
+	payment.Invoice_id = invoice.Invoice_id
+	payment.Order_id = invoice.Order_id
+	payment.Recorded_by = actor
+	payment.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if _, err := paymentCollection.InsertOne(ctx, payment); err != nil {
+		// the card is not left charged for a payment that was not recorded
+		refundCard(ctx, payment, amount)
+		return err
+	}
+
+	// a payment taken at the same time may have paid the balance already
+	paid, err = invoicePaid(ctx, invoice.Invoice_id)
+	if err != nil {
+		return err
+	}
+	if paid > due {
+		paymentCollection.DeleteOne(ctx, bson.M{"payment_id": payment.Payment_id})
+		refundCard(ctx, payment, amount)
+		return errInvoicePaid
+	}
+
+	status := "PARTIALLY_PAID"
+	if paid == due {
+		status = "PAID"
+	}
+	updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if _, err := invoiceCollection.UpdateOne(ctx,
+		bson.M{"invoice_id": invoice.Invoice_id},
+		bson.M{"$set": bson.M{"payment_status": status, "updated_at": updated_at}},
+	); err != nil {
+		return err
+	}
+	if status == "PAID" {
+		if err := settleOrder(ctx, invoice.Order_id, actor); err != nil {
+			return err
+		}
+	}
+
+	publishInvoice(ctx, "invoice.updated", invoice.Invoice_id)
+	return nil
+}
+
+// chargeCard authorizes and captures the amount on the card, voiding the
+// authorization when it cannot be captured, and returns the gateway's reference.
+func chargeCard(ctx context.Context, cardToken string, amount int64, idempotencyKey string) (string, error) {
+	if paymentGateway == nil {
+		return "", errNoGateway
+	}
+	authorization, err := paymentGateway.Authorize(ctx, payments.AuthorizeRequest{
+		Amount:          amount,
+		Card_token:      cardToken,
+		Idempotency_key: idempotencyKey,
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := paymentGateway.Capture(ctx, authorization.Reference, amount); err != nil {
+		if err := paymentGateway.Void(ctx, authorization.Reference); err != nil {
+			log.Println("card authorization was not voided:", authorization.Reference, err)
+		}
+		return "", err
+	}
+	return authorization.Reference, nil
+}
+
+// refundCard gives back a card payment that was captured but not kept.
+func refundCard(ctx context.Context, payment *models.Payment, amount int64) {
+	if *payment.Tender != "CARD" || payment.Reference == nil {
+		return
+	}
+	if _, err := paymentGateway.Refund(ctx, *payment.Reference, amount); err != nil {
+		log.Println("card payment was not refunded:", *payment.Reference, err)
+	}
+}
+
+// paymentError responds to an error taking a payment; declined cards need
+// another way to pay.
+func paymentError(c *gin.Context, err error) {
+	if errors.Is(err, payments.ErrDeclined) {
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		return
+	}
+	if err == errNoGateway {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	orderChangeError(c, err)
+}
+
+// invoicePayments are the payments made towards the invoice, oldest first.
+func invoicePayments(ctx context.Context, invoiceId string) ([]models.Payment, error) {
+	result, err := paymentCollection.Find(ctx,
+		bson.M{"invoice_id": invoiceId},
+		options.Find().SetSort(bson.M{"created_at": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	payments := []models.Payment{}
+	err = result.All(ctx, &payments)
+	return payments, err
+}
+
+// invoicePaid is the amount paid towards the invoice, in cents.
+func invoicePaid(ctx context.Context, invoiceId string) (int64, error) {
+	payments, err := invoicePayments(ctx, invoiceId)
+	if err != nil {
+		return 0, err
+	}
+	var paid int64
+	for _, payment := range payments {
+		paid += helper.ToCents(*payment.Amount)
+	}
+	return paid, nil
+}
+
+// invoiceBalance is the amount due on the invoice, tips included, and the
+// amount paid towards it, in cents.
+func invoiceBalance(ctx context.Context, invoice models.Invoice) (due int64, paid int64, err error) {
+	totals, err := invoiceTotals(ctx, invoice)
+	if err != nil {
+		return 0, 0, err
+	}
+	paid, err = invoicePaid(ctx, invoice.Invoice_id)
+	return helper.ToCents(totals.Total_with_tips), paid, err
+}
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var priceChangeCollection *mongo.Collection = database.OpenCollection(database.Client, "priceChange")
+
+func GetPriceHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve, latest first
+		foodId := c.Param("food_id")
+		filter := bson.M{"food_id": foodId}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		result, err := priceChangeCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "effective_at", Value: -1}, {Key: "created_at", Value: -1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the price history"})
+			return
+		}
+
+		// decode
+		priceChanges := []bson.M{}
+		if err = result.All(ctx, &priceChanges); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the price history"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, priceChanges)
+	}
+}
+
+func SchedulePriceChange() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var priceChange models.PriceChange
+		if err := c.BindJSON(&priceChange); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		priceChange.Status = "PENDING"
+		if err := validate.Struct(priceChange); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !priceChange.Effective_at.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "effective_at must be in the future, use PATCH /foods/:food_id to change the price now"})
+			return
+		}
+
+		priceChange.Food_id = c.Param("food_id")
+		count, err := foodCollection.CountDocuments(ctx, bson.M{"food_id": priceChange.Food_id})
+		if err != nil || count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "food was not found"})
+			return
+		}
+
+		var num = toFixed(*priceChange.Price, 2)
+		priceChange.Price = &num
+		priceChange.Created_by = c.GetString("uid")
+		priceChange.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		priceChange.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		priceChange.ID = primitive.NewObjectID()
+		priceChange.Price_change_id = priceChange.ID.Hex()
+
+		// insert
+		result, err := priceChangeCollection.InsertOne(ctx, priceChange)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "price change was not scheduled"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func CancelPriceChange() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// only pending changes can be cancelled
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := priceChangeCollection.UpdateOne(
+			ctx,
+			bson.M{"food_id": c.Param("food_id"), "price_change_id": c.Param("price_change_id"), "status": "PENDING"},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "status", Value: "CANCELLED"},
+					{Key: "updated_at", Value: updated_at},
+				}},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "price change update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "no pending price change was found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// RunPriceScheduler applies due price changes every interval. It blocks, so
+// run it in its own goroutine.
+func RunPriceScheduler(interval time.Duration) {
+	for {
+		if err := ApplyDuePriceChanges(); err != nil {
+			log.Println("applying price changes failed:", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// ApplyDuePriceChanges sets the price of every pending change whose effective
+// time has passed on its food, oldest first, so the latest due change wins.
+func ApplyDuePriceChanges() error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	result, err := priceChangeCollection.Find(ctx,
+		bson.M{"status": "PENDING", "effective_at": bson.M{"$lte": now}},
+		options.Find().SetSort(bson.D{{Key: "effective_at", Value: 1}, {Key: "created_at", Value: 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	var due []models.PriceChange
+	if err = result.All(ctx, &due); err != nil {
+		return err
+	}
+
+	for _, priceChange := range due {
+		if err := applyPriceChange(ctx, priceChange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyPriceChange sets the food's price and marks the change applied with
+// the price it replaced.
+func applyPriceChange(ctx context.Context, priceChange models.PriceChange) error {
+	var food models.Food
+	err := foodCollection.FindOneAndUpdate(ctx,
+		bson.M{"food_id": priceChange.Food_id},
+		bson.M{"$set": bson.M{"price": priceChange.Price, "updated_at": priceChange.Effective_at}},
+	).Decode(&food)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	status := "APPLIED"
+	if err == mongo.ErrNoDocuments {
+		status = "CANCELLED"
+	}
+	applied_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err = priceChangeCollection.UpdateOne(ctx,
+		bson.M{"price_change_id": priceChange.Price_change_id, "status": "PENDING"},
+		bson.M{"$set": bson.M{"status": status, "previous_price": food.Price, "applied_at": applied_at, "updated_at": applied_at}},
+	)
+	return err
+}
+
+// recordPriceChange adds a price set directly on the food to its history.
+func recordPriceChange(ctx context.Context, foodId string, previousPrice *float64, price *float64, createdBy string) error {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	priceChange := models.PriceChange{
+		ID:             primitive.NewObjectID(),
+		Food_id:        foodId,
+		Price:          price,
+		Previous_price: previousPrice,
+		Effective_at:   &now,
+		Status:         "APPLIED",
+		Applied_at:     &now,
+		Created_by:     createdBy,
+		Created_at:     now,
+		Updated_at:     now,
+	}
+	priceChange.Price_change_id = priceChange.ID.Hex()
+	_, err := priceChangeCollection.InsertOne(ctx, priceChange)
+	return err
+}
+
+// priceInEffect is the food's price at the given time, including a due
+// change the scheduler has not applied yet.
+func priceInEffect(ctx context.Context, food models.Food, at time.Time) (float64, error) {
+	var priceChange models.PriceChange
+	err := priceChangeCollection.FindOne(ctx,
+		bson.M{"food_id": food.Food_id, "status": "PENDING", "effective_at": bson.M{"$lte": at}},
+		options.FindOne().SetSort(bson.D{{Key: "effective_at", Value: -1}, {Key: "created_at", Value: -1}}),
+	).Decode(&priceChange)
+	if err == nil {
+		return *priceChange.Price, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+	if food.Price == nil {
+		return 0, nil
+	}
+	return *food.Price, nil
+}
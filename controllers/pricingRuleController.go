@@ -0,0 +1,270 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var pricingRuleCollection *mongo.Collection = database.OpenCollection(database.Client, "pricingRule")
+
+func GetPricingRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve
+		result, err := pricingRuleCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing pricing rules"})
+			return
+		}
+
+		// decode
+		allPricingRules := []bson.M{}
+		if err = result.All(ctx, &allPricingRules); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing pricing rules"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allPricingRules)
+	}
+}
+
+func GetPricingRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve and decode
+		pricingRuleId := c.Param("pricing_rule_id")
+		var pricingRule models.PricingRule
+		if err := pricingRuleCollection.FindOne(ctx, bson.M{"pricing_rule_id": pricingRuleId}).Decode(&pricingRule); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "pricing rule was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, pricingRule)
+	}
+}
+
+func CreatePricingRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var pricingRule models.PricingRule
+		if err := c.BindJSON(&pricingRule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(pricingRule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validatePricingRule(pricingRule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pricingRule.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		pricingRule.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		pricingRule.ID = primitive.NewObjectID()
+		pricingRule.Pricing_rule_id = pricingRule.ID.Hex()
+		if pricingRule.Is_active == nil {
+			active := true
+			pricingRule.Is_active = &active
+		}
+
+		// insert
+		result, err := pricingRuleCollection.InsertOne(ctx, pricingRule)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "pricing rule was not created"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdatePricingRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// a rule is replaced as a whole so its fields stay consistent
+		pricingRuleId := c.Param("pricing_rule_id")
+		var existing models.PricingRule
+		if err := pricingRuleCollection.FindOne(ctx, bson.M{"pricing_rule_id": pricingRuleId}).Decode(&existing); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "pricing rule was not found"})
+			return
+		}
+
+		pricingRule := existing
+		if err := c.BindJSON(&pricingRule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(pricingRule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validatePricingRule(pricingRule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		pricingRule.ID = existing.ID
+		pricingRule.Pricing_rule_id = existing.Pricing_rule_id
+		pricingRule.Created_at = existing.Created_at
+		pricingRule.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		// update mongodb
+		result, err := pricingRuleCollection.ReplaceOne(ctx, bson.M{"pricing_rule_id": pricingRuleId}, pricingRule)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "pricing rule update failed"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func validatePricingRule(pricingRule models.PricingRule) error {
+	if *pricingRule.Kind == "PERCENT_OFF" && *pricingRule.Value > 100 {
+		return fmt.Errorf("a percentage off must not exceed 100")
+	}
+	if (pricingRule.Start_time == nil) != (pricingRule.End_time == nil) {
+		return fmt.Errorf("start_time and end_time must be given together")
+	}
+	if pricingRule.Valid_from != nil && pricingRule.Valid_until != nil && !pricingRule.Valid_from.Before(*pricingRule.Valid_until) {
+		return fmt.Errorf("valid_from must be before valid_until")
+	}
+	return nil
+}
+
+// applyPricingRules prices a food order item with the active rule that gives
+// the lowest price at the given time, recording the rule on the line. The
+// order item's unit price must hold the food's base price.
+func applyPricingRules(ctx context.Context, orderItem *models.OrderItem, food models.Food, at time.Time) error {
+	result, err := pricingRuleCollection.Find(ctx, bson.M{"is_active": true})
+	if err != nil {
+		return err
+	}
+	var pricingRules []models.PricingRule
+	if err = result.All(ctx, &pricingRules); err != nil {
+		return err
+	}
+
+	basePrice := *orderItem.Unit_price
+	bestPrice := basePrice
+	var bestRule *models.PricingRule
+	for i, pricingRule := range pricingRules {
+		if !pricingRuleApplies(pricingRule, food, at) {
+			continue
+		}
+		if price := pricingRulePrice(pricingRule, basePrice); price < bestPrice {
+			bestPrice = price
+			bestRule = &pricingRules[i]
+		}
+	}
+
+	orderItem.Base_price = &basePrice
+	orderItem.Pricing_rule = nil
+	if bestRule != nil {
+		orderItem.Unit_price = &bestPrice
+		orderItem.Pricing_rule = &models.AppliedPricingRule{
+			Pricing_rule_id: bestRule.Pricing_rule_id,
+			Name:            *bestRule.Name,
+			Discount:        toFixed(basePrice-bestPrice, 2),
+		}
+	}
+	return nil
+}
+
+// pricingRuleApplies reports whether the rule targets the food and is in
+// effect at the given local time.
+func pricingRuleApplies(pricingRule models.PricingRule, food models.Food, at time.Time) bool {
+	if pricingRule.Valid_from != nil && at.Before(*pricingRule.Valid_from) {
+		return false
+	}
+	if pricingRule.Valid_until != nil && !at.Before(*pricingRule.Valid_until) {
+		return false
+	}
+
+	targeted := len(pricingRule.Food_ids) == 0 && len(pricingRule.Menu_ids) == 0 && len(pricingRule.Categories) == 0
+	if slices.Contains(pricingRule.Food_ids, food.Food_id) {
+		targeted = true
+	}
+	if food.Menu_id != nil && slices.Contains(pricingRule.Menu_ids, *food.Menu_id) {
+		targeted = true
+	}
+	if food.Category != nil && slices.Contains(pricingRule.Categories, *food.Category) {
+		targeted = true
+	}
+	if !targeted {
+		return false
+	}
+
+	local := at.In(time.Local)
+	day := int(local.Weekday())
+	if pricingRule.Start_time == nil {
+		return len(pricingRule.Days) == 0 || slices.Contains(pricingRule.Days, day)
+	}
+
+	start, _ := time.Parse("15:04", *pricingRule.Start_time)
+	end, _ := time.Parse("15:04", *pricingRule.End_time)
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	minute := local.Hour()*60 + local.Minute()
+
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute &&
+			(len(pricingRule.Days) == 0 || slices.Contains(pricingRule.Days, day))
+	}
+
+	// the window runs past midnight, after midnight it belongs to the previous day
+	if minute >= startMinute {
+		return len(pricingRule.Days) == 0 || slices.Contains(pricingRule.Days, day)
+	}
+	if minute < endMinute {
+		return len(pricingRule.Days) == 0 || slices.Contains(pricingRule.Days, (day+6)%7)
+	}
+	return false
+}
+
+func pricingRulePrice(pricingRule models.PricingRule, basePrice float64) float64 {
+	var price float64
+	switch *pricingRule.Kind {
+	case "PERCENT_OFF":
+		price = basePrice * (100 - *pricingRule.Value) / 100
+	case "AMOUNT_OFF":
+		price = basePrice - *pricingRule.Value
+	case "FIXED_PRICE":
+		price = *pricingRule.Value
+	default:
+		return basePrice
+	}
+	if price < 0 {
+		price = 0
+	}
+	return toFixed(price, 2)
+}
@@ -0,0 +1,558 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PurchaseOrderReceipt struct {
+	Lines     []ReceivedLine `json:"lines" validate:"required,min=1,dive"`
+	Reference *string        `json:"reference"`
+}
+
+type ReceivedLine struct {
+	Ingredient_id string  `json:"ingredient_id" validate:"required"`
+	Quantity      float64 `json:"quantity" validate:"required,gt=0"`
+}
+
+type ReorderSuggestion struct {
+	Ingredient_id       string   `json:"ingredient_id"`
+	Name                *string  `json:"name"`
+	Unit                *string  `json:"unit"`
+	Supplier_id         *string  `json:"supplier_id"`
+	On_hand             float64  `json:"on_hand"`
+	Par_level           float64  `json:"par_level"`
+	On_order            float64  `json:"on_order"`
+	Average_daily_usage float64  `json:"average_daily_usage"`
+	Lead_time_days      int      `json:"lead_time_days"`
+	Suggested_quantity  float64  `json:"suggested_quantity"`
+	Unit_cost           *float64 `json:"unit_cost"`
+}
+
+var purchaseOrderCollection *mongo.Collection = database.OpenCollection(database.Client, "purchaseOrder")
+
+func GetPurchaseOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve, optionally by status and supplier
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if supplierId := c.Query("supplier_id"); supplierId != "" {
+			filter["supplier_id"] = supplierId
+		}
+		result, err := purchaseOrderCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing purchase orders"})
+			return
+		}
+
+		// decode
+		allPurchaseOrders := []bson.M{}
+		if err = result.All(ctx, &allPurchaseOrders); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing purchase orders"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allPurchaseOrders)
+	}
+}
+
+func GetPurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve and decode
+		purchaseOrderId := c.Param("purchase_order_id")
+		var purchaseOrder models.PurchaseOrder
+		if err := purchaseOrderCollection.FindOne(ctx, bson.M{"purchase_order_id": purchaseOrderId}).Decode(&purchaseOrder); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "purchase order was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, purchaseOrder)
+	}
+}
+
+func CreatePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var purchaseOrder models.PurchaseOrder
+		if err := c.BindJSON(&purchaseOrder); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		purchaseOrder.Status = "DRAFT"
+		if err := validate.Struct(purchaseOrder); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		count, err := supplierCollection.CountDocuments(ctx, bson.M{"supplier_id": purchaseOrder.Supplier_id})
+		if err != nil || count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "supplier was not found"})
+			return
+		}
+		lines, err := purchaseOrderLines(ctx, purchaseOrder.Lines)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		purchaseOrder.Lines = lines
+		purchaseOrder.Created_by = c.GetString("uid")
+		purchaseOrder.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		purchaseOrder.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		purchaseOrder.ID = primitive.NewObjectID()
+		purchaseOrder.Purchase_order_id = purchaseOrder.ID.Hex()
+
+		// insert
+		result, err := purchaseOrderCollection.InsertOne(ctx, purchaseOrder)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "purchase order was not created"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdatePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind
+		var purchaseOrder models.PurchaseOrder
+		if err := c.BindJSON(&purchaseOrder); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// prepare updated obj, only drafts can be edited
+		var updateObj primitive.D
+		if purchaseOrder.Lines != nil {
+			if err := validate.Var(purchaseOrder.Lines, "min=1,dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			lines, err := purchaseOrderLines(ctx, purchaseOrder.Lines)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "lines", Value: lines})
+		}
+		if purchaseOrder.Expected_date != nil {
+			updateObj = append(updateObj, bson.E{Key: "expected_date", Value: purchaseOrder.Expected_date})
+		}
+		if purchaseOrder.Note != nil {
+			updateObj = append(updateObj, bson.E{Key: "note", Value: purchaseOrder.Note})
+		}
+		purchaseOrder.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: purchaseOrder.Updated_at})
+
+		// update mongodb
+		purchaseOrderId := c.Param("purchase_order_id")
+		result, err := purchaseOrderCollection.UpdateOne(
+			ctx,
+			bson.M{"purchase_order_id": purchaseOrderId, "status": "DRAFT"},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "purchase order update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "only draft purchase orders can be edited"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func SendPurchaseOrder() gin.HandlerFunc {
+	return transitionPurchaseOrderHandler([]string{"DRAFT"}, "SENT", "sent_at")
+}
+
+func CancelPurchaseOrder() gin.HandlerFunc {
+	return transitionPurchaseOrderHandler([]string{"DRAFT", "SENT"}, "CANCELLED", "")
+}
+
+func ReceivePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var receipt PurchaseOrderReceipt
+		if err := c.BindJSON(&receipt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(receipt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// only sent purchase orders can be received against
+		purchaseOrderId := c.Param("purchase_order_id")
+		var purchaseOrder models.PurchaseOrder
+		if err := purchaseOrderCollection.FindOne(ctx, bson.M{"purchase_order_id": purchaseOrderId}).Decode(&purchaseOrder); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "purchase order was not found"})
+			return
+		}
+		if purchaseOrder.Status != "SENT" && purchaseOrder.Status != "PARTIALLY_RECEIVED" {
+			c.JSON(http.StatusConflict, gin.H{"error": "purchase order is " + purchaseOrder.Status})
+			return
+		}
+
+		// receive each line up to its outstanding quantity
+		originalLines := slices.Clone(purchaseOrder.Lines)
+		unitCosts := map[string]*float64{}
+		for _, received := range receipt.Lines {
+			index := -1
+			for i, line := range purchaseOrder.Lines {
+				if line.Ingredient_id == received.Ingredient_id {
+					index = i
+				}
+			}
+			if index < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "ingredient is not on the purchase order: " + received.Ingredient_id})
+				return
+			}
+			line := &purchaseOrder.Lines[index]
+			if received.Quantity > line.Quantity-line.Received_quantity+1e-9 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("only %v of %s is outstanding", line.Quantity-line.Received_quantity, received.Ingredient_id)})
+				return
+			}
+			line.Received_quantity = toFixed(line.Received_quantity+received.Quantity, 3)
+			unitCosts[received.Ingredient_id] = line.Unit_cost
+		}
+
+		status := "RECEIVED"
+		for _, line := range purchaseOrder.Lines {
+			if line.Received_quantity < line.Quantity {
+				status = "PARTIALLY_RECEIVED"
+			}
+		}
+
+		// update the purchase order unless it changed since it was read; its
+		// lines change with every receipt, unlike updated_at within a second
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj := bson.D{
+			{Key: "lines", Value: purchaseOrder.Lines},
+			{Key: "status", Value: status},
+			{Key: "updated_at", Value: updated_at},
+		}
+		if status == "RECEIVED" {
+			updateObj = append(updateObj, bson.E{Key: "received_at", Value: updated_at})
+		}
+		result, err := purchaseOrderCollection.UpdateOne(
+			ctx,
+			bson.M{"purchase_order_id": purchaseOrderId, "status": purchaseOrder.Status, "lines": originalLines},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "purchase order update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "purchase order was changed, try again"})
+			return
+		}
+
+		// put the received quantities into stock; if any cannot be booked the
+		// receipt is undone so it can be retried
+		reference := purchaseOrderId
+		if receipt.Reference != nil {
+			reference = purchaseOrderId + " " + *receipt.Reference
+		}
+		recorded := []models.StockMovement{}
+		for _, received := range receipt.Lines {
+			ingredientId, quantity := received.Ingredient_id, received.Quantity
+			movement, err := recordStockMovement(ctx, models.StockMovement{
+				Ingredient_id: &ingredientId,
+				Type:          "RECEIPT",
+				Quantity:      &quantity,
+				Unit_cost:     unitCosts[ingredientId],
+				Reference:     &reference,
+				Recorded_by:   c.GetString("uid"),
+			})
+			if err != nil {
+				log.Println("purchase order receipt was not recorded:", purchaseOrderId, ingredientId, err)
+				for _, movement := range recorded {
+					if err := reverseStockMovement(ctx, movement); err != nil {
+						log.Println("stock receipt was not reversed:", movement.Movement_id, err)
+					}
+				}
+				purchaseOrderCollection.UpdateOne(ctx,
+					bson.M{"purchase_order_id": purchaseOrderId, "lines": purchaseOrder.Lines},
+					bson.M{"$set": bson.M{
+						"lines":       originalLines,
+						"status":      purchaseOrder.Status,
+						"received_at": purchaseOrder.Received_at,
+						"updated_at":  purchaseOrder.Updated_at,
+					}},
+				)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "stock could not be booked for " + ingredientId + ", the receipt was not recorded"})
+				return
+			}
+			recorded = append(recorded, movement)
+		}
+
+		// response
+		c.JSON(http.StatusOK, gin.H{"purchase_order_id": purchaseOrderId, "status": status, "lines": purchaseOrder.Lines})
+	}
+}
+
+func GetReorderSuggestions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// consumption is averaged over the last "days" days
+		days, err := strconv.Atoi(c.Query("days"))
+		if err != nil || days < 1 {
+			days = 14
+		}
+
+		// ingredients with a par level
+		result, err := ingredientCollection.Find(ctx, bson.M{"par_level": bson.M{"$ne": nil}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing ingredients"})
+			return
+		}
+		var ingredients []models.Ingredient
+		if err = result.All(ctx, &ingredients); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing ingredients"})
+			return
+		}
+
+		// recent consumption, quantities on order, supplier lead times and last costs
+		since := time.Now().AddDate(0, 0, -days)
+		consumed, err := sumByIngredient(ctx, stockMovementCollection, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: bson.M{"type": "SALE", "recorded_at": bson.M{"$gte": since}}}},
+			bson.D{{Key: "$group", Value: bson.M{"_id": "$ingredient_id", "total": bson.M{"$sum": "$quantity"}}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while summing consumption"})
+			return
+		}
+		onOrder, err := sumByIngredient(ctx, purchaseOrderCollection, mongo.Pipeline{
+			bson.D{{Key: "$match", Value: bson.M{"status": bson.M{"$in": bson.A{"SENT", "PARTIALLY_RECEIVED"}}}}},
+			bson.D{{Key: "$unwind", Value: "$lines"}},
+			bson.D{{Key: "$group", Value: bson.M{
+				"_id":   "$lines.ingredient_id",
+				"total": bson.M{"$sum": bson.M{"$subtract": bson.A{"$lines.quantity", "$lines.received_quantity"}}},
+			}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while summing quantities on order"})
+			return
+		}
+		leadTimes, err := supplierLeadTimes(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing suppliers"})
+			return
+		}
+		lastCosts, err := lastUnitCosts(ctx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while reading unit costs"})
+			return
+		}
+
+		// suggest topping up to par plus the usage expected during the lead time
+		suggestions := []ReorderSuggestion{}
+		for _, ingredient := range ingredients {
+			suggestion := ReorderSuggestion{
+				Ingredient_id:       ingredient.Ingredient_id,
+				Name:                ingredient.Name,
+				Unit:                ingredient.Unit,
+				Supplier_id:         ingredient.Supplier_id,
+				Par_level:           *ingredient.Par_level,
+				On_order:            toFixed(onOrder[ingredient.Ingredient_id], 3),
+				Average_daily_usage: toFixed(consumed[ingredient.Ingredient_id]/float64(days), 3),
+				Unit_cost:           lastCosts[ingredient.Ingredient_id],
+			}
+			if ingredient.On_hand != nil {
+				suggestion.On_hand = *ingredient.On_hand
+			}
+			if ingredient.Supplier_id != nil {
+				suggestion.Lead_time_days = leadTimes[*ingredient.Supplier_id]
+			}
+
+			needed := suggestion.Par_level + suggestion.Average_daily_usage*float64(suggestion.Lead_time_days) - suggestion.On_hand - suggestion.On_order
+			if needed <= 0 {
+				continue
+			}
+			suggestion.Suggested_quantity = math.Ceil(needed*100) / 100
+			suggestions = append(suggestions, suggestion)
+		}
+
+		// response
+		c.JSON(http.StatusOK, gin.H{"days": days, "suggestions": suggestions})
+	}
+}
+
+func transitionPurchaseOrderHandler(from []string, to string, timestampField string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// prepare updated obj
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj := bson.D{
+			{Key: "status", Value: to},
+			{Key: "updated_at", Value: updated_at},
+		}
+		if timestampField != "" {
+			updateObj = append(updateObj, bson.E{Key: timestampField, Value: updated_at})
+		}
+
+		// update mongodb if the purchase order is in an allowed status
+		purchaseOrderId := c.Param("purchase_order_id")
+		result, err := purchaseOrderCollection.UpdateOne(
+			ctx,
+			bson.M{"purchase_order_id": purchaseOrderId, "status": bson.M{"$in": from}},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "purchase order update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("purchase order cannot become %s from its current status", to)})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// purchaseOrderLines checks every ingredient exists and is ordered once and
+// resets the received quantities.
+func purchaseOrderLines(ctx context.Context, lines []models.PurchaseOrderLine) ([]models.PurchaseOrderLine, error) {
+	seen := map[string]bool{}
+	for i := range lines {
+		if seen[lines[i].Ingredient_id] {
+			return nil, fmt.Errorf("duplicate purchase order ingredient: %s", lines[i].Ingredient_id)
+		}
+		seen[lines[i].Ingredient_id] = true
+
+		count, err := ingredientCollection.CountDocuments(ctx, bson.M{"ingredient_id": lines[i].Ingredient_id})
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("ingredient was not found: %s", lines[i].Ingredient_id)
+		}
+		lines[i].Received_quantity = 0
+	}
+	return lines, nil
+}
+
+// sumByIngredient runs a pipeline grouping "total" by ingredient ID.
+func sumByIngredient(ctx context.Context, collection *mongo.Collection, pipeline mongo.Pipeline) (map[string]float64, error) {
+	result, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Id    string  `bson:"_id"`
+		Total float64 `bson:"total"`
+	}
+	if err = result.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	totals := map[string]float64{}
+	for _, row := range rows {
+		totals[row.Id] = row.Total
+	}
+	return totals, nil
+}
+
+func supplierLeadTimes(ctx context.Context) (map[string]int, error) {
+	result, err := supplierCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var suppliers []models.Supplier
+	if err = result.All(ctx, &suppliers); err != nil {
+		return nil, err
+	}
+
+	leadTimes := map[string]int{}
+	for _, supplier := range suppliers {
+		if supplier.Lead_time_days != nil {
+			leadTimes[supplier.Supplier_id] = *supplier.Lead_time_days
+		}
+	}
+	return leadTimes, nil
+}
+
+// lastUnitCosts reads the unit cost of each ingredient's latest costed receipt.
+func lastUnitCosts(ctx context.Context) (map[string]*float64, error) {
+	result, err := stockMovementCollection.Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{"type": "RECEIPT", "unit_cost": bson.M{"$ne": nil}}}},
+		bson.D{{Key: "$sort", Value: bson.M{"recorded_at": -1}}},
+		bson.D{{Key: "$group", Value: bson.M{"_id": "$ingredient_id", "unit_cost": bson.M{"$first": "$unit_cost"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Id        string  `bson:"_id"`
+		Unit_cost float64 `bson:"unit_cost"`
+	}
+	if err = result.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	costs := map[string]*float64{}
+	for _, row := range rows {
+		cost := row.Unit_cost
+		costs[row.Id] = &cost
+	}
+	return costs, nil
+}
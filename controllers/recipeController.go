@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var recipeCollection *mongo.Collection = database.OpenCollection(database.Client, "recipe")
+
+func GetRecipes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve, optionally by food
+		filter := bson.M{}
+		if foodId := c.Query("food_id"); foodId != "" {
+			filter["food_id"] = foodId
+		}
+		result, err := recipeCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing recipes"})
+			return
+		}
+
+		// decode
+		allRecipes := []bson.M{}
+		if err = result.All(ctx, &allRecipes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing recipes"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allRecipes)
+	}
+}
+
+func GetRecipe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve and decode
+		recipeId := c.Param("recipe_id")
+		var recipe models.Recipe
+		if err := recipeCollection.FindOne(ctx, bson.M{"recipe_id": recipeId}).Decode(&recipe); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, recipe)
+	}
+}
+
+func CreateRecipe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var recipe models.Recipe
+		if err := c.BindJSON(&recipe); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(recipe); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// one recipe per food and size
+		count, err := foodCollection.CountDocuments(ctx, bson.M{"food_id": recipe.Food_id})
+		if err != nil || count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "food was not found"})
+			return
+		}
+		count, err = recipeCollection.CountDocuments(ctx, bson.M{"food_id": recipe.Food_id, "size": recipe.Size})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking for the recipe"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "this food already has a recipe for this size"})
+			return
+		}
+		if err := validateRecipeLines(ctx, recipe.Lines); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		recipe.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		recipe.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		recipe.ID = primitive.NewObjectID()
+		recipe.Recipe_id = recipe.ID.Hex()
+
+		// insert
+		result, err := recipeCollection.InsertOne(ctx, recipe)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "recipe was not created"})
+			return
+		}
+		refreshRecipeAvailability(ctx, recipe.Lines)
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateRecipe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind
+		var recipe models.Recipe
+		if err := c.BindJSON(&recipe); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if recipe.Lines == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lines are required"})
+			return
+		}
+		if err := validate.Var(recipe.Lines, "min=1,dive"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateRecipeLines(ctx, recipe.Lines); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// the ingredients it had are needed to re-enable foods it no longer uses
+		recipeId := c.Param("recipe_id")
+		var existing models.Recipe
+		if err := recipeCollection.FindOne(ctx, bson.M{"recipe_id": recipeId}).Decode(&existing); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe was not found"})
+			return
+		}
+
+		// update mongodb
+		recipe.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := recipeCollection.UpdateOne(
+			ctx,
+			bson.M{"recipe_id": recipeId},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "lines", Value: recipe.Lines},
+					{Key: "updated_at", Value: recipe.Updated_at},
+				}},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "recipe update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe was not found"})
+			return
+		}
+		refreshRecipeAvailability(ctx, append(existing.Lines, recipe.Lines...))
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// refreshRecipeAvailability updates whether the foods using the ingredients
+// of lines are available, as a recipe change can make them low or no longer
+// needed.
+func refreshRecipeAvailability(ctx context.Context, lines []models.RecipeLine) {
+	var ingredientIds []string
+	for _, line := range lines {
+		ingredientIds = append(ingredientIds, line.Ingredient_id)
+	}
+	if err := refreshStockAvailability(ctx, ingredientIds); err != nil {
+		log.Println("stock availability refresh failed:", err)
+	}
+}
+
+// recipeFor finds the recipe for a food in the given size, falling back to
+// the food's sizeless recipe.
+func recipeFor(ctx context.Context, foodId string, size *string) (models.Recipe, error) {
+	var recipe models.Recipe
+	if size != nil {
+		err := recipeCollection.FindOne(ctx, bson.M{"food_id": foodId, "size": *size}).Decode(&recipe)
+		if err != mongo.ErrNoDocuments {
+			return recipe, err
+		}
+	}
+	err := recipeCollection.FindOne(ctx, bson.M{"food_id": foodId, "size": nil}).Decode(&recipe)
+	return recipe, err
+}
+
+func validateRecipeLines(ctx context.Context, lines []models.RecipeLine) error {
+	seen := map[string]bool{}
+	for _, line := range lines {
+		if seen[line.Ingredient_id] {
+			return fmt.Errorf("duplicate recipe ingredient: %s", line.Ingredient_id)
+		}
+		seen[line.Ingredient_id] = true
+
+		count, err := ingredientCollection.CountDocuments(ctx, bson.M{"ingredient_id": line.Ingredient_id})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("ingredient was not found: %s", line.Ingredient_id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/helper"
+	"restaurant-management-backend/models"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var refundCollection *mongo.Collection = database.OpenCollection(database.Client, "refund")
+var creditNoteCollection *mongo.Collection = database.OpenCollection(database.Client, "creditNote")
+
+// RefundRequest refunds part of a payment, or what is left of it when no
+// amount is given. Refunds are approved by a manager, signed in or entering
+// their credentials.
+type RefundRequest struct {
+	Amount           *float64            `json:"amount" validate:"omitempty,gt=0"`
+	Reason_code      *string             `json:"reason_code" validate:"required"`
+	Note             *string             `json:"note" validate:"omitempty,max=200"`
+	Manager_approval *ManagerCredentials `json:"manager_approval"`
+}
+
+func RefundPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var request RefundRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !models.IsRefundReason(*request.Reason_code) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reason_code must be one of the refund reasons", "reasons": models.RefundReasons})
+			return
+		}
+
+		// only payments of paid invoices are refunded
+		var payment models.Payment
+		if err := paymentCollection.FindOne(ctx, bson.M{"payment_id": c.Param("payment_id")}).Decode(&payment); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "payment was not found"})
+			return
+		}
+		var invoice models.Invoice
+		if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": payment.Invoice_id}).Decode(&invoice); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": errInvoiceNotFound.Error()})
+			return
+		}
+		if invoice.Payment_status == nil || *invoice.Payment_status != "PAID" {
+			c.JSON(http.StatusConflict, gin.H{"error": "only payments of paid invoices are refunded"})
+			return
+		}
+
+		// no more than what is left of the payment
+		refunded, err := refundedAmount(ctx, bson.M{"payment_id": payment.Payment_id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the payment's refunds"})
+			return
+		}
+		remaining := helper.ToCents(*payment.Amount) - refunded
+		amount := remaining
+		if request.Amount != nil {
+			amount = helper.ToCents(*request.Amount)
+		}
+		if remaining <= 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "payment was already refunded"})
+			return
+		}
+		if amount > remaining {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("refund of %.2f is more than the %.2f left of the payment", helper.FromCents(amount), helper.FromCents(remaining))})
+			return
+		}
+
+		approvedBy, err := managerApproval(ctx, c.GetString("uid"), request.Manager_approval)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+
+		// record the refund first so a refund made at the same time cannot
+		// give back more than was paid
+		refund := models.Refund{
+			Payment_id:   payment.Payment_id,
+			Invoice_id:   payment.Invoice_id,
+			Order_id:     payment.Order_id,
+			Tender:       *payment.Tender,
+			Amount:       helper.FromCents(amount),
+			Reason_code:  *request.Reason_code,
+			Note:         request.Note,
+			Requested_by: c.GetString("uid"),
+			Approved_by:  approvedBy,
+		}
+		refund.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		refund.ID = primitive.NewObjectID()
+		refund.Refund_id = refund.ID.Hex()
+		creditNote := models.CreditNote{
+			Invoice_id:  refund.Invoice_id,
+			Order_id:    refund.Order_id,
+			Refund_id:   refund.Refund_id,
+			Amount:      refund.Amount,
+			Reason_code: refund.Reason_code,
+			Note:        refund.Note,
+			Issued_by:   approvedBy,
+			Created_at:  refund.Created_at,
+		}
+		creditNote.ID = primitive.NewObjectID()
+		creditNote.Credit_note_id = creditNote.ID.Hex()
+		refund.Credit_note_id = creditNote.Credit_note_id
+		if _, err := refundCollection.InsertOne(ctx, refund); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "refund was not created"})
+			return
+		}
+		if refunded, err = refundedAmount(ctx, bson.M{"payment_id": payment.Payment_id}); err != nil || refunded > helper.ToCents(*payment.Amount) {
+			refundCollection.DeleteOne(ctx, bson.M{"refund_id": refund.Refund_id})
+			c.JSON(http.StatusConflict, gin.H{"error": "payment was refunded at the same time, try again"})
+			return
+		}
+
+		// document it with a credit note against the invoice before any
+		// money goes back
+		undo := func() {
+			creditNoteCollection.DeleteOne(ctx, bson.M{"credit_note_id": creditNote.Credit_note_id})
+			refundCollection.DeleteOne(ctx, bson.M{"refund_id": refund.Refund_id})
+		}
+		if _, err := creditNoteCollection.InsertOne(ctx, creditNote); err != nil {
+			undo()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "credit note was not created, the payment was not refunded"})
+			return
+		}
+
+		// card refunds go back through the payment gateway
+		if *payment.Tender == "CARD" && payment.Reference != nil {
+			if paymentGateway == nil || paymentGateway.Name() != payment.Gateway {
+				undo()
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": fmt.Sprintf("the %s payment gateway the payment was taken with is not configured", payment.Gateway)})
+				return
+			}
+			reference, err := paymentGateway.Refund(ctx, *payment.Reference, amount)
+			if err != nil {
+				undo()
+				c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("payment gateway did not refund the payment: %s", err)})
+				return
+			}
+			refund.Gateway_reference = &reference
+			if _, err := refundCollection.UpdateOne(ctx,
+				bson.M{"refund_id": refund.Refund_id},
+				bson.M{"$set": bson.M{"gateway_reference": refund.Gateway_reference}},
+			); err != nil {
+				log.Println("refund gateway reference was not recorded:", refund.Refund_id, reference, err)
+			}
+		}
+
+		publishInvoice(ctx, "invoice.refunded", refund.Invoice_id)
+
+		// response
+		c.JSON(http.StatusOK, gin.H{"refund": refund, "credit_note": creditNote})
+	}
+}
+
+func GetCreditNotes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve
+		filter := bson.M{}
+		if invoiceId := c.Query("invoice_id"); invoiceId != "" {
+			filter["invoice_id"] = invoiceId
+		}
+		result, err := creditNoteCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing credit notes"})
+			return
+		}
+
+		// decode
+		allCreditNotes := []bson.M{}
+		if err = result.All(ctx, &allCreditNotes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing credit notes"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allCreditNotes)
+	}
+}
+
+func GetCreditNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve and decode
+		var creditNote models.CreditNote
+		if err := creditNoteCollection.FindOne(ctx, bson.M{"credit_note_id": c.Param("credit_note_id")}).Decode(&creditNote); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "credit note was not found"})
+			return
+		}
+		var invoice models.Invoice
+		if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": creditNote.Invoice_id}).Decode(&invoice); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": errInvoiceNotFound.Error()})
+			return
+		}
+		totals, err := invoiceTotals(ctx, invoice)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while calculating the invoice totals"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, gin.H{"credit_note": creditNote, "invoice": invoice, "invoice_total": totals.Total_with_tips})
+	}
+}
+
+// SalesDay is a day's takings. Payments cover tips, which go to staff, so
+// Gross is what was taken and Net the sales: less what was refunded that day
+// and the tips left that day.
+type SalesDay struct {
+	Date      string             `json:"date"`
+	Payments  int                `json:"payments"`
+	Gross     float64            `json:"gross"`
+	Refunds   int                `json:"refunds"`
+	Refunded  float64            `json:"refunded"`
+	Tips      float64            `json:"tips"`
+	Net       float64            `json:"net"`
+	By_tender map[string]float64 `json:"by_tender"`
+}
+
+func GetSalesReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// payments and refunds in the period
+		from, to, err := reportPeriod(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		period := bson.M{"created_at": bson.M{"$gte": from, "$lt": to}}
+		var allPayments []models.Payment
+		result, err := paymentCollection.Find(ctx, period)
+		if err == nil {
+			err = result.All(ctx, &allPayments)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the sales report"})
+			return
+		}
+		var allRefunds []models.Refund
+		result, err = refundCollection.Find(ctx, period)
+		if err == nil {
+			err = result.All(ctx, &allRefunds)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the sales report"})
+			return
+		}
+
+		var tippedInvoices []models.Invoice
+		result, err = invoiceCollection.Find(ctx, bson.M{"tips.created_at": bson.M{"$gte": from, "$lt": to}})
+		if err == nil {
+			err = result.All(ctx, &tippedInvoices)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the sales report"})
+			return
+		}
+
+		// sum by local day and tender, in cents
+		type daySums struct {
+			payments, refunds     int
+			gross, refunded, tips int64
+			byTender              map[string]int64
+		}
+		days := map[string]*daySums{}
+		day := func(at time.Time) *daySums {
+			date := at.In(time.Local).Format("2006-01-02")
+			if days[date] == nil {
+				days[date] = &daySums{byTender: map[string]int64{}}
+			}
+			return days[date]
+		}
+		for _, payment := range allPayments {
+			sums := day(payment.Created_at)
+			amount := helper.ToCents(*payment.Amount)
+			sums.payments++
+			sums.gross += amount
+			sums.byTender[*payment.Tender] += amount
+		}
+		for _, refund := range allRefunds {
+			sums := day(refund.Created_at)
+			amount := helper.ToCents(refund.Amount)
+			sums.refunds++
+			sums.refunded += amount
+			sums.byTender[refund.Tender] -= amount
+		}
+		for _, invoice := range tippedInvoices {
+			for _, tip := range invoice.Tips {
+				if tip.Amount != nil && !tip.Created_at.Before(from) && tip.Created_at.Before(to) {
+					day(tip.Created_at).tips += helper.ToCents(*tip.Amount)
+				}
+			}
+		}
+
+		dates := []string{}
+		for date := range days {
+			dates = append(dates, date)
+		}
+		slices.Sort(dates)
+		report := []SalesDay{}
+		var gross, refunded, tips int64
+		for _, date := range dates {
+			sums := days[date]
+			salesDay := SalesDay{
+				Date:      date,
+				Payments:  sums.payments,
+				Gross:     helper.FromCents(sums.gross),
+				Refunds:   sums.refunds,
+				Refunded:  helper.FromCents(sums.refunded),
+				Tips:      helper.FromCents(sums.tips),
+				Net:       helper.FromCents(sums.gross - sums.refunded - sums.tips),
+				By_tender: map[string]float64{},
+			}
+			for tender, amount := range sums.byTender {
+				salesDay.By_tender[tender] = helper.FromCents(amount)
+			}
+			report = append(report, salesDay)
+			gross += sums.gross
+			refunded += sums.refunded
+			tips += sums.tips
+		}
+
+		// response
+		c.JSON(http.StatusOK, gin.H{
+			"from":     from,
+			"to":       to,
+			"days":     report,
+			"gross":    helper.FromCents(gross),
+			"refunded": helper.FromCents(refunded),
+			"tips":     helper.FromCents(tips),
+			"net":      helper.FromCents(gross - refunded - tips),
+		})
+	}
+}
+
+// refundedAmount is the amount refunded by the refunds matching the filter,
+// e.g. of a payment or an invoice, in cents.
+func refundedAmount(ctx context.Context, filter bson.M) (int64, error) {
+	result, err := refundCollection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	var refunds []models.Refund
+	if err = result.All(ctx, &refunds); err != nil {
+		return 0, err
+	}
+	var refunded int64
+	for _, refund := range refunds {
+		refunded += helper.ToCents(refund.Amount)
+	}
+	return refunded, nil
+}
@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var serviceChargeRuleCollection *mongo.Collection = database.OpenCollection(database.Client, "serviceChargeRule")
+
+func GetServiceChargeRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve
+		result, err := serviceChargeRuleCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"min_guests": 1}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing service charge rules"})
+			return
+		}
+
+		// decode
+		allRules := []bson.M{}
+		if err = result.All(ctx, &allRules); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing service charge rules"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allRules)
+	}
+}
+
+func GetServiceChargeRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve and decode
+		ruleId := c.Param("service_charge_rule_id")
+		var rule models.ServiceChargeRule
+		if err := serviceChargeRuleCollection.FindOne(ctx, bson.M{"service_charge_rule_id": ruleId}).Decode(&rule); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "service charge rule was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+func CreateServiceChargeRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var rule models.ServiceChargeRule
+		if err := c.BindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if *rule.Kind == "PERCENT" && *rule.Value > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a percentage service charge must not exceed 100"})
+			return
+		}
+
+		rule.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		rule.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		rule.ID = primitive.NewObjectID()
+		rule.Service_charge_rule_id = rule.ID.Hex()
+		if rule.Is_active == nil {
+			active := true
+			rule.Is_active = &active
+		}
+
+		// insert
+		result, err := serviceChargeRuleCollection.InsertOne(ctx, rule)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "service charge rule was not created"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateServiceChargeRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind
+		var rule models.ServiceChargeRule
+		if err := c.BindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// prepare updated obj, invoices keep the charge they were raised with
+		var updateObj primitive.D
+		if rule.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: rule.Name})
+		}
+		if rule.Kind != nil || rule.Value != nil {
+			if rule.Kind == nil || rule.Value == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "kind and value must be changed together"})
+				return
+			}
+			if err := validate.StructPartial(rule, "Kind", "Value"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if *rule.Kind == "PERCENT" && *rule.Value > 100 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "a percentage service charge must not exceed 100"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "kind", Value: rule.Kind})
+			updateObj = append(updateObj, bson.E{Key: "value", Value: rule.Value})
+		}
+		if rule.Min_guests != nil {
+			if *rule.Min_guests < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "min_guests must be at least 1"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "min_guests", Value: rule.Min_guests})
+		}
+		if rule.Is_active != nil {
+			updateObj = append(updateObj, bson.E{Key: "is_active", Value: rule.Is_active})
+		}
+		rule.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: rule.Updated_at})
+
+		// update mongodb
+		ruleId := c.Param("service_charge_rule_id")
+		result, err := serviceChargeRuleCollection.UpdateOne(
+			ctx,
+			bson.M{"service_charge_rule_id": ruleId},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "service charge rule update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "service charge rule was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func WaiveServiceCharge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// remove from the invoice, before anything is paid
+		invoiceId := c.Param("invoice_id")
+		if err := invoiceOpenForCharges(ctx, invoiceId, true); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := invoiceCollection.UpdateOne(ctx,
+			bson.M{"invoice_id": invoiceId},
+			bson.M{"$set": bson.M{"service_charge": nil, "updated_at": updated_at}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "service charge was not waived"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice was not found"})
+			return
+		}
+
+		publishInvoice(ctx, "invoice.updated", invoiceId)
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// serviceChargeFor finds the active rule for the party at the order's table,
+// nil when the party is too small for any rule.
+func serviceChargeFor(ctx context.Context, order models.Order) (*models.AppliedServiceCharge, error) {
+	var table models.Table
+	if err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if table.Number_of_guests == nil {
+		return nil, nil
+	}
+
+	var rule models.ServiceChargeRule
+	err := serviceChargeRuleCollection.FindOne(ctx,
+		bson.M{"is_active": true, "min_guests": bson.M{"$lte": *table.Number_of_guests}},
+		options.FindOne().SetSort(bson.D{{Key: "min_guests", Value: -1}, {Key: "service_charge_rule_id", Value: 1}}),
+	).Decode(&rule)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AppliedServiceCharge{
+		Service_charge_rule_id: rule.Service_charge_rule_id,
+		Name:                   *rule.Name,
+		Kind:                   *rule.Kind,
+		Value:                  *rule.Value,
+		Guests:                 *table.Number_of_guests,
+	}, nil
+}
@@ -0,0 +1,320 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management-backend/helper"
+	"restaurant-management-backend/models"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BillSplit splits an order into invoices by the order items each pays for,
+// by seat, or evenly a number of ways.
+type BillSplit struct {
+	Mode           *string    `json:"mode" validate:"required,eq=ITEMS|eq=SEATS|eq=EVEN"`
+	Parts          [][]string `json:"parts" validate:"required_if=Mode ITEMS"`
+	Ways           *int       `json:"ways" validate:"required_if=Mode EVEN,omitempty,min=2,max=20"`
+	Payment_method *string    `json:"payment_method" validate:"omitempty,eq=CARD|eq=CASH"`
+}
+
+type SplitInvoice struct {
+	Invoice_id string              `json:"invoice_id"`
+	Split      models.InvoiceSplit `json:"split"`
+	Total      float64             `json:"total"`
+}
+
+func SplitOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var billSplit BillSplit
+		if err := c.BindJSON(&billSplit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(billSplit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// served orders are split, billed ones split again while nothing is paid
+		orderId := c.Param("order_id")
+		var order models.Order
+		if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": errOrderNotFound.Error()})
+			return
+		}
+		status := orderStatus(order)
+		if status != "SERVED" && status != "BILLED" {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("a %s order cannot be split", status)})
+			return
+		}
+		if err := orderHasNoPayments(ctx, orderId); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+		replaced, err := orderInvoiceIds(ctx, orderId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the order's invoices"})
+			return
+		}
+		if err := invoicesHaveNoTips(ctx, replaced); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+
+		// work out the parts
+		orderItems, err := billableOrderItems(ctx, orderId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing the order's items"})
+			return
+		}
+		splits, err := splitParts(billSplit, orderItems)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// one invoice per part, sharing the order's service charge
+		serviceCharge, err := serviceChargeFor(ctx, order)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while looking up the service charge"})
+			return
+		}
+		invoices := []models.Invoice{}
+		invoicesToBeInserted := []interface{}{}
+		inserted := []string{}
+		splitId := primitive.NewObjectID().Hex()
+		for i := range splits {
+			splits[i].Split_id = splitId
+			pending := "PENDING"
+			invoice := models.Invoice{
+				Order_id:       orderId,
+				Payment_method: billSplit.Payment_method,
+				Payment_status: &pending,
+				Service_charge: serviceCharge,
+				Tips:           []models.Tip{},
+				Split:          &splits[i],
+			}
+			invoice.Payment_due_date, _ = time.Parse(time.RFC3339, time.Now().AddDate(0, 0, 1).Format(time.RFC3339))
+			invoice.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			invoice.ID = primitive.NewObjectID()
+			invoice.Invoice_id = invoice.ID.Hex()
+			invoices = append(invoices, invoice)
+			invoicesToBeInserted = append(invoicesToBeInserted, invoice)
+			inserted = append(inserted, invoice.Invoice_id)
+		}
+
+		// the split invoices are in place before the ones they replace go
+		if _, err := invoiceCollection.InsertMany(ctx, invoicesToBeInserted); err != nil {
+			invoiceCollection.DeleteMany(ctx, bson.M{"invoice_id": bson.M{"$in": inserted}})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "split invoices were not created"})
+			return
+		}
+		if status == "SERVED" {
+			if _, err := transitionOrder(ctx, orderId, "BILLED", c.GetString("uid"), nil); err != nil {
+				invoiceCollection.DeleteMany(ctx, bson.M{"invoice_id": bson.M{"$in": inserted}})
+				orderChangeError(c, err)
+				return
+			}
+		}
+		if len(replaced) > 0 {
+			for _, invoiceId := range replaced {
+				publishInvoice(ctx, "invoice.deleted", invoiceId)
+			}
+			if _, err := invoiceCollection.DeleteMany(ctx, bson.M{"invoice_id": bson.M{"$in": replaced}}); err != nil {
+				invoiceCollection.DeleteMany(ctx, bson.M{"invoice_id": bson.M{"$in": inserted}})
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while replacing the order's invoices"})
+				return
+			}
+		}
+
+		// the parts add up to the order
+		totals, err := orderTotals(ctx, orderId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while calculating the invoice totals"})
+			return
+		}
+		helper.AddServiceCharge(&totals, serviceCharge)
+		response := []SplitInvoice{}
+		for _, invoice := range invoices {
+			invoiceTotal, err := invoiceTotals(ctx, invoice)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while calculating the invoice totals"})
+				return
+			}
+			response = append(response, SplitInvoice{Invoice_id: invoice.Invoice_id, Split: *invoice.Split, Total: invoiceTotal.Total})
+			publishInvoice(ctx, "invoice.created", invoice.Invoice_id)
+		}
+
+		// response
+		c.JSON(http.StatusOK, gin.H{"order_id": orderId, "total": totals.Total, "invoices": response})
+	}
+}
+
+// billableOrderItems are the order's items that are charged, in the order
+// they were added.
+func billableOrderItems(ctx context.Context, orderId string) ([]models.OrderItem, error) {
+	result, err := orderItemCollection.Find(ctx,
+		bson.M{"order_id": orderId, "adjustment": nil, "status": bson.M{"$ne": "VOIDED"}},
+		options.Find().SetSort(bson.M{"created_at": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	orderItems := []models.OrderItem{}
+	err = result.All(ctx, &orderItems)
+	return orderItems, err
+}
+
+// splitParts works out which order items (or which even share) each invoice
+// of a split pays for. Every charged order item is paid for exactly once.
+func splitParts(billSplit BillSplit, orderItems []models.OrderItem) ([]models.InvoiceSplit, error) {
+	splits := []models.InvoiceSplit{}
+	switch *billSplit.Mode {
+	case "EVEN":
+		if billSplit.Ways == nil {
+			return nil, fmt.Errorf("an even split needs the number of ways")
+		}
+		for part := 1; part <= *billSplit.Ways; part++ {
+			splits = append(splits, models.InvoiceSplit{Mode: "EVEN", Part: part, Parts: *billSplit.Ways})
+		}
+		return splits, nil
+
+	case "ITEMS":
+		assigned := map[string]bool{}
+		for _, orderItem := range orderItems {
+			assigned[orderItem.Order_item_id] = false
+		}
+		for _, orderItemIds := range billSplit.Parts {
+			if len(orderItemIds) == 0 {
+				return nil, fmt.Errorf("every part must pay for at least one order item")
+			}
+			for _, orderItemId := range orderItemIds {
+				done, ok := assigned[orderItemId]
+				if !ok {
+					return nil, fmt.Errorf("order item is not charged on this order: %s", orderItemId)
+				}
+				if done {
+					return nil, fmt.Errorf("order item is in more than one part: %s", orderItemId)
+				}
+				assigned[orderItemId] = true
+			}
+			splits = append(splits, models.InvoiceSplit{Mode: "ITEMS", Order_item_ids: orderItemIds})
+		}
+		for orderItemId, done := range assigned {
+			if !done {
+				return nil, fmt.Errorf("order item is not in any part: %s", orderItemId)
+			}
+		}
+
+	case "SEATS":
+		bySeat := map[int][]string{}
+		for _, orderItem := range orderItems {
+			if orderItem.Seat == nil {
+				return nil, fmt.Errorf("order item has no seat: %s", orderItem.Order_item_id)
+			}
+			bySeat[*orderItem.Seat] = append(bySeat[*orderItem.Seat], orderItem.Order_item_id)
+		}
+		seats := []int{}
+		for seat := range bySeat {
+			seats = append(seats, seat)
+		}
+		slices.Sort(seats)
+		for _, seat := range seats {
+			splits = append(splits, models.InvoiceSplit{Mode: "SEATS", Order_item_ids: bySeat[seat], Seats: []int{seat}})
+		}
+	}
+
+	if len(splits) < 2 {
+		return nil, fmt.Errorf("a split needs at least two parts")
+	}
+	for i := range splits {
+		splits[i].Part = i + 1
+		splits[i].Parts = len(splits)
+	}
+	return splits, nil
+}
+
+// splitOrderItemIds are the order items each part of the split pays for.
+func splitOrderItemIds(ctx context.Context, split models.InvoiceSplit, parts int) ([][]string, error) {
+	result, err := invoiceCollection.Find(ctx, bson.M{"split.split_id": split.Split_id})
+	if err != nil {
+		return nil, err
+	}
+	var invoices []models.Invoice
+	if err = result.All(ctx, &invoices); err != nil {
+		return nil, err
+	}
+
+	orderItemIds := make([][]string, parts)
+	for _, invoice := range invoices {
+		if part := invoice.Split.Part; part >= 1 && part <= parts {
+			orderItemIds[part-1] = invoice.Split.Order_item_ids
+		}
+	}
+	return orderItemIds, nil
+}
+
+// orderInvoiceIds are the ids of the order's invoices.
+func orderInvoiceIds(ctx context.Context, orderId string) ([]string, error) {
+	result, err := invoiceCollection.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return nil, err
+	}
+	var invoices []models.Invoice
+	if err = result.All(ctx, &invoices); err != nil {
+		return nil, err
+	}
+	invoiceIds := []string{}
+	for _, invoice := range invoices {
+		invoiceIds = append(invoiceIds, invoice.Invoice_id)
+	}
+	return invoiceIds, nil
+}
+
+// invoicesHaveNoTips returns an error when any of the invoices carries tips,
+// which would be lost when they are replaced.
+func invoicesHaveNoTips(ctx context.Context, invoiceIds []string) error {
+	tipped, err := invoiceCollection.CountDocuments(ctx, bson.M{"invoice_id": bson.M{"$in": invoiceIds}, "tips.0": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	if tipped > 0 {
+		return fmt.Errorf("the order's invoices carry tips, remove them before the invoices are replaced")
+	}
+	return nil
+}
+
+// splitOrderDetails keeps the order details of the order items a part pays for.
+func splitOrderDetails(orderDetails interface{}, orderItemIds []string) interface{} {
+	details, ok := orderDetails.(primitive.A)
+	if !ok {
+		return orderDetails
+	}
+	kept := primitive.A{}
+	for _, detail := range details {
+		var orderItemId interface{}
+		switch item := detail.(type) {
+		case primitive.M:
+			orderItemId = item["order_item_id"]
+		case primitive.D:
+			orderItemId = item.Map()["order_item_id"]
+		}
+		if id, ok := orderItemId.(string); ok && slices.Contains(orderItemIds, id) {
+			kept = append(kept, detail)
+		}
+	}
+	return kept
+}
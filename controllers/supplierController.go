@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var supplierCollection *mongo.Collection = database.OpenCollection(database.Client, "supplier")
+
+func GetSuppliers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve
+		result, err := supplierCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing suppliers"})
+			return
+		}
+
+		// decode
+		allSuppliers := []bson.M{}
+		if err = result.All(ctx, &allSuppliers); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing suppliers"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allSuppliers)
+	}
+}
+
+func GetSupplier() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve and decode
+		supplierId := c.Param("supplier_id")
+		var supplier models.Supplier
+		if err := supplierCollection.FindOne(ctx, bson.M{"supplier_id": supplierId}).Decode(&supplier); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "supplier was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, supplier)
+	}
+}
+
+func CreateSupplier() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var supplier models.Supplier
+		if err := c.BindJSON(&supplier); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(supplier); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		supplier.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		supplier.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		supplier.ID = primitive.NewObjectID()
+		supplier.Supplier_id = supplier.ID.Hex()
+
+		// insert
+		result, err := supplierCollection.InsertOne(ctx, supplier)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "supplier was not created"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateSupplier() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind
+		var supplier models.Supplier
+		if err := c.BindJSON(&supplier); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// prepare updated obj
+		var updateObj primitive.D
+		if supplier.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: supplier.Name})
+		}
+		if supplier.Email != nil {
+			if err := validate.Var(*supplier.Email, "email"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "email", Value: supplier.Email})
+		}
+		if supplier.Phone != nil {
+			updateObj = append(updateObj, bson.E{Key: "phone", Value: supplier.Phone})
+		}
+		if supplier.Lead_time_days != nil {
+			if *supplier.Lead_time_days < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "lead_time_days must not be negative"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "lead_time_days", Value: supplier.Lead_time_days})
+		}
+		supplier.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: supplier.Updated_at})
+
+		// update mongodb
+		supplierId := c.Param("supplier_id")
+		result, err := supplierCollection.UpdateOne(
+			ctx,
+			bson.M{"supplier_id": supplierId},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "supplier update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "supplier was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -90,6 +90,8 @@ func CreateTable() gin.HandlerFunc {
 			return
 		}
 
+		publishTable(ctx, "table.created", table.Table_id)
+
 		// response
 		c.JSON(http.StatusOK, result)
 	}
@@ -143,6 +145,8 @@ func UpdateTable() gin.HandlerFunc {
 			return
 		}
 
+		publishTable(ctx, "table.updated", tableId)
+
 		// response
 		c.JSON(http.StatusOK, result)
 	}
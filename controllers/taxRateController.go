@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management-backend/database"
+	"restaurant-management-backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var taxRateCollection *mongo.Collection = database.OpenCollection(database.Client, "taxRate")
+
+// foods and combos without a tax category are taxed as food
+const defaultTaxCategory = "FOOD"
+
+func GetTaxRates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve
+		filter := bson.M{}
+		if category := c.Query("tax_category"); category != "" {
+			filter["tax_category"] = category
+		}
+		result, err := taxRateCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "tax_category", Value: 1}, {Key: "name", Value: 1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing tax rates"})
+			return
+		}
+
+		// decode
+		allTaxRates := []bson.M{}
+		if err = result.All(ctx, &allTaxRates); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing tax rates"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, allTaxRates)
+	}
+}
+
+func GetTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// retrieve and decode
+		taxRateId := c.Param("tax_rate_id")
+		var taxRate models.TaxRate
+		if err := taxRateCollection.FindOne(ctx, bson.M{"tax_rate_id": taxRateId}).Decode(&taxRate); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tax rate was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, taxRate)
+	}
+}
+
+func CreateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var taxRate models.TaxRate
+		if err := c.BindJSON(&taxRate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(taxRate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		taxRate.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		taxRate.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		taxRate.ID = primitive.NewObjectID()
+		taxRate.Tax_rate_id = taxRate.ID.Hex()
+		if taxRate.Is_active == nil {
+			active := true
+			taxRate.Is_active = &active
+		}
+
+		// insert
+		result, err := taxRateCollection.InsertOne(ctx, taxRate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "tax rate was not created"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind
+		var taxRate models.TaxRate
+		if err := c.BindJSON(&taxRate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// prepare updated obj, order items keep the rates they were ordered at
+		var updateObj primitive.D
+		if taxRate.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: taxRate.Name})
+		}
+		if taxRate.Tax_category != nil {
+			if err := validate.Var(*taxRate.Tax_category, "eq=FOOD|eq=ALCOHOL|eq=TAKEAWAY"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "tax_category must be one of FOOD, ALCOHOL or TAKEAWAY"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "tax_category", Value: taxRate.Tax_category})
+		}
+		if taxRate.Rate != nil {
+			if *taxRate.Rate < 0 || *taxRate.Rate > 100 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "rate must be between 0 and 100"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "rate", Value: taxRate.Rate})
+		}
+		if taxRate.Is_inclusive != nil {
+			updateObj = append(updateObj, bson.E{Key: "is_inclusive", Value: taxRate.Is_inclusive})
+		}
+		if taxRate.Is_active != nil {
+			updateObj = append(updateObj, bson.E{Key: "is_active", Value: taxRate.Is_active})
+		}
+		taxRate.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: taxRate.Updated_at})
+
+		// update mongodb
+		taxRateId := c.Param("tax_rate_id")
+		result, err := taxRateCollection.UpdateOne(
+			ctx,
+			bson.M{"tax_rate_id": taxRateId},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "tax rate update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tax rate was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// snapshotTaxes keeps the active rates of the order item's tax category on
+// it, so later rate changes do not alter what was charged.
+func snapshotTaxes(ctx context.Context, orderItem *models.OrderItem) error {
+	if orderItem.Tax_category == nil {
+		category := defaultTaxCategory
+		orderItem.Tax_category = &category
+	}
+
+	result, err := taxRateCollection.Find(ctx,
+		bson.M{"tax_category": orderItem.Tax_category, "is_active": true},
+		options.Find().SetSort(bson.M{"tax_rate_id": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	var taxRates []models.TaxRate
+	if err = result.All(ctx, &taxRates); err != nil {
+		return err
+	}
+
+	orderItem.Taxes = []models.AppliedTax{}
+	for _, taxRate := range taxRates {
+		orderItem.Taxes = append(orderItem.Taxes, models.AppliedTax{
+			Tax_rate_id:  taxRate.Tax_rate_id,
+			Name:         *taxRate.Name,
+			Rate:         *taxRate.Rate,
+			Is_inclusive: *taxRate.Is_inclusive,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management-backend/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func AddTip() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// bind and validate
+		var tip models.Tip
+		if err := c.BindJSON(&tip); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validate.Struct(tip); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// the tip goes to the signed in user unless given to someone else
+		if tip.Staff_id == nil {
+			uid := c.GetString("uid")
+			tip.Staff_id = &uid
+		}
+		count, err := userCollection.CountDocuments(ctx, bson.M{"user_id": tip.Staff_id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking the staff member"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "staff member was not found"})
+			return
+		}
+
+		var num = toFixed(*tip.Amount, 2)
+		tip.Amount = &num
+		tip.Tip_id = primitive.NewObjectID().Hex()
+		tip.Recorded_by = c.GetString("uid")
+		tip.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		// add to the invoice, while it is still being paid
+		invoiceId := c.Param("invoice_id")
+		if err := invoiceOpenForCharges(ctx, invoiceId, false); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+		result, err := invoiceCollection.UpdateOne(ctx,
+			bson.M{"invoice_id": invoiceId},
+			bson.M{"$push": bson.M{"tips": tip}, "$set": bson.M{"updated_at": tip.Created_at}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "tip was not added"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice was not found"})
+			return
+		}
+
+		publishInvoice(ctx, "invoice.updated", invoiceId)
+
+		// response
+		c.JSON(http.StatusOK, tip)
+	}
+}
+
+func RemoveTip() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// remove from the invoice, before anything is paid
+		invoiceId := c.Param("invoice_id")
+		tipId := c.Param("tip_id")
+		if err := invoiceOpenForCharges(ctx, invoiceId, true); err != nil {
+			orderChangeError(c, err)
+			return
+		}
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := invoiceCollection.UpdateOne(ctx,
+			bson.M{"invoice_id": invoiceId, "tips.tip_id": tipId},
+			bson.M{"$pull": bson.M{"tips": bson.M{"tip_id": tipId}}, "$set": bson.M{"updated_at": updated_at}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "tip was not removed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tip was not found"})
+			return
+		}
+
+		publishInvoice(ctx, "invoice.updated", invoiceId)
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func GetTipReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// filter by period and staff member
+		from, to, err := reportPeriod(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		match := bson.M{"tips.created_at": bson.M{"$gte": from, "$lt": to}}
+		if staffId := c.Query("staff_id"); staffId != "" {
+			match["tips.staff_id"] = staffId
+		}
+
+		// sum the tips of each staff member by payment method
+		sumOfMethod := func(method string) bson.D {
+			return bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{"$tips.payment_method", method}}},
+				"$tips.amount",
+				0,
+			}}}}}
+		}
+		result, err := invoiceCollection.Aggregate(ctx, mongo.Pipeline{
+			bson.D{{Key: "$unwind", Value: "$tips"}},
+			bson.D{{Key: "$match", Value: match}},
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$tips.staff_id"},
+				{Key: "tip_count", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "invoice_ids", Value: bson.D{{Key: "$addToSet", Value: "$invoice_id"}}},
+				{Key: "card", Value: sumOfMethod("CARD")},
+				{Key: "cash", Value: sumOfMethod("CASH")},
+				{Key: "total", Value: bson.D{{Key: "$sum", Value: "$tips.amount"}}},
+			}}},
+			bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "user"}, {Key: "localField", Value: "_id"}, {Key: "foreignField", Value: "user_id"}, {Key: "as", Value: "staff"}}}},
+			bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$staff"}, {Key: "preserveNullAndEmptyArrays", Value: true}}}},
+			bson.D{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "staff_id", Value: "$_id"},
+				{Key: "first_name", Value: "$staff.first_name"},
+				{Key: "last_name", Value: "$staff.last_name"},
+				{Key: "tip_count", Value: 1},
+				{Key: "invoice_count", Value: bson.D{{Key: "$size", Value: "$invoice_ids"}}},
+				{Key: "card", Value: bson.D{{Key: "$round", Value: bson.A{"$card", 2}}}},
+				{Key: "cash", Value: bson.D{{Key: "$round", Value: bson.A{"$cash", 2}}}},
+				{Key: "total", Value: bson.D{{Key: "$round", Value: bson.A{"$total", 2}}}},
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: "total", Value: -1}, {Key: "staff_id", Value: 1}}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the tip report"})
+			return
+		}
+
+		// decode
+		staff := []bson.M{}
+		if err = result.All(ctx, &staff); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while building the tip report"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "staff": staff})
+	}
+}
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"restaurant-management-backend/helper"
+	"restaurant-management-backend/models"
+	"restaurant-management-backend/storage"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type uploadedImage struct {
+	Url           string
+	Thumbnail_url string
+	keys          []string
+}
+
+const thumbnailSize = 256
+
+var blobStore storage.BlobStore = storage.NewLocalStore(storage.UPLOAD_DIR, storage.UPLOAD_URL)
+
+var maxUploadSize int64 = uploadSizeLimit()
+
+func UploadFoodImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// check the food exists
+		foodId := c.Param("food_id")
+		var food models.Food
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "food was not found"})
+			return
+		}
+
+		// store the image and its thumbnail
+		uploaded, status, err := storeUploadedImage(ctx, c, "foods/"+foodId)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		// save the served URLs on the food
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := foodCollection.UpdateOne(
+			ctx,
+			bson.M{"food_id": foodId},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "food_image", Value: uploaded.Url},
+					{Key: "food_thumbnail", Value: uploaded.Thumbnail_url},
+					{Key: "updated_at", Value: updated_at},
+				}},
+			},
+		)
+		if err != nil {
+			discardUploadedImage(ctx, uploaded)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "food image update failed"})
+			return
+		}
+		discardStoredImages(ctx, food.Food_image, food.Food_thumbnail)
+
+		// response
+		c.JSON(http.StatusOK, gin.H{"food_image": uploaded.Url, "food_thumbnail": uploaded.Thumbnail_url, "result": result})
+	}
+}
+
+func UploadUserAvatar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// users can only change their own avatar
+		userId := c.Param("user_id")
+		if c.GetString("uid") != userId {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own avatar"})
+			return
+		}
+
+		var user models.User
+		if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user was not found"})
+			return
+		}
+
+		// store the image and its thumbnail
+		uploaded, status, err := storeUploadedImage(ctx, c, "users/"+userId)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		// save the served URLs on the user
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "avatar", Value: uploaded.Url},
+					{Key: "avatar_thumbnail", Value: uploaded.Thumbnail_url},
+					{Key: "updated_at", Value: updated_at},
+				}},
+			},
+		)
+		if err != nil || result.MatchedCount == 0 {
+			discardUploadedImage(ctx, uploaded)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "avatar update failed"})
+			return
+		}
+		discardStoredImages(ctx, user.Avatar, user.Avatar_thumbnail)
+
+		// response
+		c.JSON(http.StatusOK, gin.H{"avatar": uploaded.Url, "avatar_thumbnail": uploaded.Thumbnail_url, "result": result})
+	}
+}
+
+// storeUploadedImage validates the "image" multipart file and stores it with
+// a thumbnail under prefix. On failure it returns the HTTP status to respond with.
+func storeUploadedImage(ctx context.Context, c *gin.Context, prefix string) (uploaded uploadedImage, status int, err error) {
+	// read the file, enforcing the size limit
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		return uploaded, http.StatusBadRequest, fmt.Errorf("image file is required")
+	}
+	if fileHeader.Size > maxUploadSize {
+		return uploaded, http.StatusRequestEntityTooLarge, fmt.Errorf("image must not exceed %d bytes", maxUploadSize)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return uploaded, http.StatusBadRequest, fmt.Errorf("image file could not be read")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+	if err != nil {
+		return uploaded, http.StatusBadRequest, fmt.Errorf("image file could not be read")
+	}
+	if int64(len(data)) > maxUploadSize {
+		return uploaded, http.StatusRequestEntityTooLarge, fmt.Errorf("image must not exceed %d bytes", maxUploadSize)
+	}
+
+	// validate the content type and generate the thumbnail
+	img, contentType, extension, err := helper.DecodeImage(data)
+	if err != nil {
+		return uploaded, http.StatusUnsupportedMediaType, err
+	}
+	thumbnail, thumbnailType, thumbnailExtension, err := helper.EncodeImage(helper.Thumbnail(img, thumbnailSize), contentType)
+	if err != nil {
+		return uploaded, http.StatusInternalServerError, fmt.Errorf("thumbnail could not be generated")
+	}
+
+	// store both
+	name := prefix + "/" + primitive.NewObjectID().Hex()
+	key := name + extension
+	uploaded.Url, err = blobStore.Put(ctx, key, bytes.NewReader(data), contentType)
+	if err != nil {
+		return uploaded, http.StatusInternalServerError, fmt.Errorf("image could not be stored")
+	}
+	uploaded.keys = append(uploaded.keys, key)
+
+	thumbnailKey := name + "_thumb" + thumbnailExtension
+	uploaded.Thumbnail_url, err = blobStore.Put(ctx, thumbnailKey, bytes.NewReader(thumbnail), thumbnailType)
+	if err != nil {
+		discardUploadedImage(ctx, uploaded)
+		return uploaded, http.StatusInternalServerError, fmt.Errorf("thumbnail could not be stored")
+	}
+	uploaded.keys = append(uploaded.keys, thumbnailKey)
+
+	return uploaded, http.StatusOK, nil
+}
+
+func discardUploadedImage(ctx context.Context, uploaded uploadedImage) {
+	for _, key := range uploaded.keys {
+		blobStore.Delete(ctx, key)
+	}
+}
+
+// discardStoredImages deletes the images a replaced upload was served from.
+// URLs that are not served from the upload directory are left alone.
+func discardStoredImages(ctx context.Context, urls ...*string) {
+	for _, url := range urls {
+		if url == nil {
+			continue
+		}
+		if key, ok := strings.CutPrefix(*url, storage.UPLOAD_URL+"/"); ok {
+			blobStore.Delete(ctx, key)
+		}
+	}
+}
+
+func uploadSizeLimit() int64 {
+	limit, err := strconv.ParseInt(os.Getenv("MAX_UPLOAD_SIZE"), 10, 64)
+	if err != nil || limit < 1 {
+		limit = 5 << 20
+	}
+	return limit
+}
@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"regexp"
 	"restaurant-management-backend/database"
 	"restaurant-management-backend/helper"
 	"restaurant-management-backend/models"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -99,20 +102,20 @@ func SignUp() gin.HandlerFunc {
 			return
 		}
 
-		// check if already exist
-		count, err := userCollection.CountDocuments(ctx, bson.M{"email": user.Email})
+		// check if already exist, emails whatever their case
+		emailCount, err := userCollection.CountDocuments(ctx, emailFilter(*user.Email))
 		if err != nil {
 			log.Panic(err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking for the email"})
 			return
 		}
-		count, err = userCollection.CountDocuments(ctx, bson.M{"phone": user.Phone})
+		phoneCount, err := userCollection.CountDocuments(ctx, bson.M{"phone": user.Phone})
 		if err != nil {
 			log.Panic(err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while checking for the phone number"})
 			return
 		}
-		if count > 0 {
+		if emailCount > 0 || phoneCount > 0 {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "this email or phone number already exsits"})
 			return
 		}
@@ -127,6 +130,17 @@ func SignUp() gin.HandlerFunc {
 		user.ID = primitive.NewObjectID()
 		user.User_id = user.ID.Hex()
 
+		// staff sign up as staff, the first user and MANAGER_EMAIL manage the
+		// restaurant; only one account can ever have that email
+		role := "STAFF"
+		if users, err := userCollection.CountDocuments(ctx, bson.M{}); err == nil && users == 0 {
+			role = "MANAGER"
+		}
+		if email := managerEmail(); email != "" && strings.EqualFold(*user.Email, email) {
+			role = "MANAGER"
+		}
+		user.Role = &role
+
 		// generate token
 		token, refreshToken, _ := helper.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, user.User_id)
 		user.Token = &token
@@ -204,3 +218,84 @@ func VerifyPassword(userPassword string, providedPassword string) (bool, string)
 	}
 	return check, msg
 }
+
+func UpdateUserRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// context with timeout
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		// only managers change roles
+		if !isManager(ctx, c.GetString("uid")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only a manager can change roles"})
+			return
+		}
+
+		// bind and validate
+		var user models.User
+		if err := c.BindJSON(&user); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if user.Role == nil || (*user.Role != "STAFF" && *user.Role != "MANAGER") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be STAFF or MANAGER"})
+			return
+		}
+
+		// update mongodb
+		userId := c.Param("user_id")
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := userCollection.UpdateOne(ctx,
+			bson.M{"user_id": userId},
+			bson.M{"$set": bson.M{"role": user.Role, "updated_at": updated_at}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "user role update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user was not found"})
+			return
+		}
+
+		// response
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// EnsureManager makes the user signed up with MANAGER_EMAIL a manager, so a
+// database whose users predate roles has someone to approve and set roles.
+func EnsureManager() error {
+	email := managerEmail()
+	if email == "" {
+		return nil
+	}
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := userCollection.UpdateOne(ctx,
+		emailFilter(email),
+		bson.M{"$set": bson.M{"role": "MANAGER"}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		log.Println("MANAGER_EMAIL has not signed up yet, they become a manager when they do:", email)
+	}
+	return nil
+}
+
+// emailFilter matches the user with email, whatever its case.
+func emailFilter(email string) bson.M {
+	return bson.M{"email": bson.M{"$regex": "^" + regexp.QuoteMeta(email) + "$", "$options": "i"}}
+}
+
+func managerEmail() string {
+	return strings.TrimSpace(os.Getenv("MANAGER_EMAIL"))
+}
+
+func isManager(ctx context.Context, userId string) bool {
+	count, err := userCollection.CountDocuments(ctx, bson.M{"user_id": userId, "role": "MANAGER"})
+	return err == nil && count > 0
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Event is a change to an order, order item, table or invoice, carrying the
+// ids subscribers filter on and the changed document.
+type Event struct {
+	Type          string      `json:"type"`
+	Order_id      string      `json:"order_id,omitempty"`
+	Order_item_id string      `json:"order_item_id,omitempty"`
+	Table_id      string      `json:"table_id,omitempty"`
+	Invoice_id    string      `json:"invoice_id,omitempty"`
+	Stations      []string    `json:"stations,omitempty"`
+	Data          interface{} `json:"data"`
+	At            time.Time   `json:"at"`
+}
+
+// Filter selects the events a subscriber receives; empty fields match everything.
+type Filter struct {
+	Types    []string
+	Station  string
+	Table_id string
+	Order_id string
+}
+
+func (f Filter) Matches(event Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+	if f.Station != "" && !slices.Contains(event.Stations, f.Station) {
+		return false
+	}
+	if f.Table_id != "" && event.Table_id != f.Table_id {
+		return false
+	}
+	if f.Order_id != "" && event.Order_id != f.Order_id {
+		return false
+	}
+	return true
+}
+
+// Bus delivers published events to the subscribers whose filter matches.
+// Publishing never blocks: a subscriber that falls too far behind misses
+// events rather than holding up the request that published them.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter Filter
+	bus    *Bus
+}
+
+// subscriptionBuffer is how many events a subscriber can fall behind by.
+const subscriptionBuffer = 64
+
+// Default is the process wide bus controllers publish to.
+var Default = NewBus()
+
+func NewBus() *Bus {
+	return &Bus{subscribers: map[*Subscription]struct{}{}}
+}
+
+func (b *Bus) Subscribe(filter Filter) *Subscription {
+	ch := make(chan Event, subscriptionBuffer)
+	subscription := &Subscription{C: ch, ch: ch, filter: filter, bus: b}
+
+	b.mu.Lock()
+	b.subscribers[subscription] = struct{}{}
+	b.mu.Unlock()
+	return subscription
+}
+
+func (b *Bus) Publish(event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for subscription := range b.subscribers {
+		if !subscription.filter.Matches(event) {
+			continue
+		}
+		select {
+		case subscription.ch <- event:
+		default:
+		}
+	}
+}
+
+// Close stops the subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subscribers[s]; ok {
+		delete(s.bus.subscribers, s)
+		close(s.ch)
+	}
+}
+
+func Publish(event Event) {
+	Default.Publish(event)
+}
+
+func Subscribe(filter Filter) *Subscription {
+	return Default.Subscribe(filter)
+}
@@ -0,0 +1,98 @@
+package helper
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// maxImagePixels caps the decoded size of an image: a small file can declare
+// huge dimensions, and decoding it allocates memory for every pixel.
+const maxImagePixels = 40_000_000
+
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// DecodeImage sniffs the content type of data, rejecting anything that is
+// not a JPEG, PNG or GIF or that is larger than maxImagePixels, and decodes it.
+func DecodeImage(data []byte) (img image.Image, contentType string, extension string, err error) {
+	contentType = http.DetectContentType(data)
+	extension, ok := allowedImageTypes[contentType]
+	if !ok {
+		return nil, "", "", fmt.Errorf("unsupported image type: %s", contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("invalid image: %s", err.Error())
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > maxImagePixels {
+		return nil, "", "", fmt.Errorf("image must not exceed %d pixels, it is %dx%d", maxImagePixels, config.Width, config.Height)
+	}
+
+	img, _, err = image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", "", fmt.Errorf("invalid image: %s", err.Error())
+	}
+	return img, contentType, extension, nil
+}
+
+// Thumbnail scales img down so neither side exceeds maxSize, averaging the
+// source pixels covered by each thumbnail pixel. Smaller images are returned as is.
+func Thumbnail(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return img
+	}
+
+	thumbWidth, thumbHeight := maxSize, maxSize
+	if width > height {
+		thumbHeight = max(1, height*maxSize/width)
+	} else {
+		thumbWidth = max(1, width*maxSize/height)
+	}
+
+	thumb := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for y := 0; y < thumbHeight; y++ {
+		y0 := bounds.Min.Y + y*height/thumbHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/thumbHeight)
+		for x := 0; x < thumbWidth; x++ {
+			x0 := bounds.Min.X + x*width/thumbWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/thumbWidth)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			thumb.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return thumb
+}
+
+// EncodeImage encodes img as PNG for PNG and GIF sources and as JPEG otherwise.
+func EncodeImage(img image.Image, contentType string) ([]byte, string, string, error) {
+	var buf bytes.Buffer
+	if contentType == "image/png" || contentType == "image/gif" {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "image/png", ".png", nil
+	}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), "image/jpeg", ".jpg", nil
+}
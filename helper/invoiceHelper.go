@@ -0,0 +1,324 @@
+package helper
+
+import (
+	"math"
+	"restaurant-management-backend/models"
+	"slices"
+)
+
+// InvoiceTotals are worked out in cents so they always add up exactly.
+//
+// Rounding is half away from zero to the cent and is done once per line and
+// tax rate; every total is the sum of those rounded amounts, so the lines,
+// the rate breakdown and the totals always agree.
+type InvoiceTotals struct {
+	Lines           []InvoiceLine     `json:"lines"`
+	Discounts       []InvoiceDiscount `json:"discounts"`
+	Taxes           []InvoiceTax      `json:"taxes"`
+	Subtotal        float64           `json:"subtotal"`
+	Line_discounts  float64           `json:"line_discounts"`
+	Order_discounts float64           `json:"order_discounts"`
+	Discount_total  float64           `json:"discount_total"`
+	Net             float64           `json:"net"`
+	Inclusive_tax   float64           `json:"inclusive_tax"`
+	Exclusive_tax   float64           `json:"exclusive_tax"`
+	Tax_total       float64           `json:"tax_total"`
+	Service_charge  float64           `json:"service_charge"`
+	Total           float64           `json:"total"`
+	Tip_total       float64           `json:"tip_total"`
+	Total_with_tips float64           `json:"total_with_tips"`
+}
+
+type InvoiceLine struct {
+	Order_item_id  string       `json:"order_item_id"`
+	Gross          float64      `json:"gross"`
+	Discount       float64      `json:"discount"`
+	Order_discount float64      `json:"order_discount"`
+	Net            float64      `json:"net"`
+	Tax            float64      `json:"tax"`
+	Total          float64      `json:"total"`
+	Taxes          []InvoiceTax `json:"taxes"`
+}
+
+type InvoiceDiscount struct {
+	Discount_id string  `json:"discount_id"`
+	Reason      *string `json:"reason"`
+	Promo_code  *string `json:"promo_code"`
+	Amount      float64 `json:"amount"`
+}
+
+// InvoiceTax is the tax charged at one rate on a line or, summed, on the invoice.
+type InvoiceTax struct {
+	Tax_rate_id  string  `json:"tax_rate_id"`
+	Name         string  `json:"name"`
+	Rate         float64 `json:"rate"`
+	Is_inclusive bool    `json:"is_inclusive"`
+	Taxable      float64 `json:"taxable"`
+	Amount       float64 `json:"amount"`
+}
+
+type lineTax struct {
+	tax     models.AppliedTax
+	taxable int64
+	amount  int64
+}
+
+// CalculateInvoiceTotals prices each order item less its own discount, then
+// applies the order discounts in turn to what is left, so discounts never
+// exceed the amount they apply to. Order discounts are shared out over the
+// lines in proportion to their net before each line is taxed at the rates
+// snapshotted on it.
+func CalculateInvoiceTotals(orderItems []models.OrderItem, orderDiscounts []models.Discount) InvoiceTotals {
+	totals := InvoiceTotals{Lines: []InvoiceLine{}, Discounts: []InvoiceDiscount{}, Taxes: []InvoiceTax{}}
+
+	// voided and comped items are not charged
+	orderItems = slices.DeleteFunc(slices.Clone(orderItems), func(orderItem models.OrderItem) bool {
+		return orderItem.Adjustment != nil || (orderItem.Status != nil && *orderItem.Status == "VOIDED")
+	})
+
+	var subtotal, lineDiscounts int64
+	gross := make([]int64, len(orderItems))
+	lineDiscount := make([]int64, len(orderItems))
+	lineNet := make([]int64, len(orderItems))
+	for i, orderItem := range orderItems {
+		if orderItem.Unit_price != nil {
+			gross[i] = ToCents(*orderItem.Unit_price)
+		}
+		lineDiscount[i] = DiscountCents(orderItem.Discount, gross[i])
+		lineNet[i] = gross[i] - lineDiscount[i]
+
+		subtotal += gross[i]
+		lineDiscounts += lineDiscount[i]
+	}
+
+	remaining := subtotal - lineDiscounts
+	var orderDiscountTotal int64
+	for i := range orderDiscounts {
+		discount := DiscountCents(&orderDiscounts[i], remaining)
+		remaining -= discount
+		orderDiscountTotal += discount
+		totals.Discounts = append(totals.Discounts, InvoiceDiscount{
+			Discount_id: orderDiscounts[i].Discount_id,
+			Reason:      orderDiscounts[i].Reason,
+			Promo_code:  orderDiscounts[i].Promo_code,
+			Amount:      FromCents(discount),
+		})
+	}
+	orderDiscountShares := AllocateCents(orderDiscountTotal, lineNet)
+
+	var inclusiveTax, exclusiveTax int64
+	rates := map[string]int{}
+	rateTaxable := []int64{}
+	rateAmount := []int64{}
+	for i, orderItem := range orderItems {
+		net := lineNet[i] - orderDiscountShares[i]
+		line := InvoiceLine{
+			Order_item_id:  orderItem.Order_item_id,
+			Gross:          FromCents(gross[i]),
+			Discount:       FromCents(lineDiscount[i]),
+			Order_discount: FromCents(orderDiscountShares[i]),
+			Net:            FromCents(net),
+			Taxes:          []InvoiceTax{},
+		}
+
+		var lineTaxTotal, lineExclusive int64
+		for _, tax := range taxLine(net, orderItem.Taxes) {
+			lineTaxTotal += tax.amount
+			if tax.tax.Is_inclusive {
+				inclusiveTax += tax.amount
+			} else {
+				lineExclusive += tax.amount
+			}
+			line.Taxes = append(line.Taxes, invoiceTax(tax.tax, tax.taxable, tax.amount))
+
+			// sum by rate in the order the rates are first seen
+			j, ok := rates[tax.tax.Tax_rate_id]
+			if !ok {
+				j = len(totals.Taxes)
+				rates[tax.tax.Tax_rate_id] = j
+				totals.Taxes = append(totals.Taxes, invoiceTax(tax.tax, 0, 0))
+				rateTaxable = append(rateTaxable, 0)
+				rateAmount = append(rateAmount, 0)
+			}
+			rateTaxable[j] += tax.taxable
+			rateAmount[j] += tax.amount
+		}
+		exclusiveTax += lineExclusive
+		line.Tax = FromCents(lineTaxTotal)
+		line.Total = FromCents(net + lineExclusive)
+		totals.Lines = append(totals.Lines, line)
+	}
+	for j := range totals.Taxes {
+		totals.Taxes[j].Taxable = FromCents(rateTaxable[j])
+		totals.Taxes[j].Amount = FromCents(rateAmount[j])
+	}
+
+	totals.Subtotal = FromCents(subtotal)
+	totals.Line_discounts = FromCents(lineDiscounts)
+	totals.Order_discounts = FromCents(orderDiscountTotal)
+	totals.Discount_total = FromCents(lineDiscounts + orderDiscountTotal)
+	totals.Net = FromCents(remaining)
+	totals.Inclusive_tax = FromCents(inclusiveTax)
+	totals.Exclusive_tax = FromCents(exclusiveTax)
+	totals.Tax_total = FromCents(inclusiveTax + exclusiveTax)
+	totals.Total = FromCents(remaining + exclusiveTax)
+	totals.Total_with_tips = totals.Total
+	return totals
+}
+
+// AddServiceCharge adds the service charge to the amount due. A percentage
+// charge is taken of the net after discounts and is not taxed.
+func AddServiceCharge(totals *InvoiceTotals, charge *models.AppliedServiceCharge) {
+	if charge == nil {
+		return
+	}
+
+	var cents int64
+	switch charge.Kind {
+	case "PERCENT":
+		cents = RoundDiv(ToCents(totals.Net)*basisPoints(charge.Value), 10000)
+	case "FIXED":
+		cents = ToCents(charge.Value)
+	}
+	totals.Service_charge = FromCents(ToCents(totals.Service_charge) + cents)
+	totals.Total = FromCents(ToCents(totals.Total) + cents)
+	totals.Total_with_tips = FromCents(ToCents(totals.Total_with_tips) + cents)
+}
+
+// AddTips adds the tips on top of the amount due; they go to staff, not to sales.
+func AddTips(totals *InvoiceTotals, tips []models.Tip) {
+	var cents int64
+	for _, tip := range tips {
+		if tip.Amount != nil {
+			cents += ToCents(*tip.Amount)
+		}
+	}
+	totals.Tip_total = FromCents(ToCents(totals.Tip_total) + cents)
+	totals.Total_with_tips = FromCents(ToCents(totals.Total_with_tips) + cents)
+}
+
+// taxLine taxes a line whose net amount in cents includes its inclusive
+// taxes. The inclusive tax is taken out of the net as a whole and shared
+// over the inclusive rates; exclusive rates are charged on what is left.
+func taxLine(net int64, taxes []models.AppliedTax) []lineTax {
+	var inclusiveBps int64
+	inclusiveWeights := []int64{}
+	for _, tax := range taxes {
+		if tax.Is_inclusive {
+			inclusiveBps += basisPoints(tax.Rate)
+			inclusiveWeights = append(inclusiveWeights, basisPoints(tax.Rate))
+		}
+	}
+	inclusive := RoundDiv(net*inclusiveBps, 10000+inclusiveBps)
+	inclusiveShares := AllocateCents(inclusive, inclusiveWeights)
+	taxable := net - inclusive
+
+	lineTaxes := []lineTax{}
+	for _, tax := range taxes {
+		amount := RoundDiv(taxable*basisPoints(tax.Rate), 10000)
+		if tax.Is_inclusive {
+			amount, inclusiveShares = inclusiveShares[0], inclusiveShares[1:]
+		}
+		lineTaxes = append(lineTaxes, lineTax{tax: tax, taxable: taxable, amount: amount})
+	}
+	return lineTaxes
+}
+
+func invoiceTax(tax models.AppliedTax, taxable, amount int64) InvoiceTax {
+	return InvoiceTax{
+		Tax_rate_id:  tax.Tax_rate_id,
+		Name:         tax.Name,
+		Rate:         tax.Rate,
+		Is_inclusive: tax.Is_inclusive,
+		Taxable:      FromCents(taxable),
+		Amount:       FromCents(amount),
+	}
+}
+
+// DiscountCents is the discount taken off amount, rounded half away from
+// zero to the cent and never more than amount.
+func DiscountCents(discount *models.Discount, amount int64) int64 {
+	if discount == nil || discount.Kind == nil || discount.Value == nil || amount <= 0 {
+		return 0
+	}
+
+	var cents int64
+	switch *discount.Kind {
+	case "PERCENT":
+		cents = RoundDiv(amount*basisPoints(*discount.Value), 10000)
+	case "FIXED":
+		cents = ToCents(*discount.Value)
+	}
+	return min(max(cents, 0), amount)
+}
+
+// AllocateCents shares total out in proportion to weights using the largest
+// remainder method, so the shares always add up to total. Ties go to the
+// earlier weight; without any weight the total is shared evenly. A negative
+// total is shared like its absolute value, every share negated.
+func AllocateCents(total int64, weights []int64) []int64 {
+	if total < 0 {
+		shares := AllocateCents(-total, weights)
+		for i := range shares {
+			shares[i] = -shares[i]
+		}
+		return shares
+	}
+
+	shares := make([]int64, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+
+	var sum int64
+	for _, weight := range weights {
+		sum += max(weight, 0)
+	}
+	if sum == 0 {
+		weights = make([]int64, len(weights))
+		for i := range weights {
+			weights[i] = 1
+		}
+		sum = int64(len(weights))
+	}
+
+	remainders := make([]int64, len(weights))
+	allocated := int64(0)
+	for i, weight := range weights {
+		weight = max(weight, 0)
+		shares[i] = total * weight / sum
+		remainders[i] = total * weight % sum
+		allocated += shares[i]
+	}
+	for left := total - allocated; left > 0; left-- {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		shares[largest]++
+		remainders[largest] = -1
+	}
+	return shares
+}
+
+// RoundDiv divides n by a positive d rounding half away from zero.
+func RoundDiv(n, d int64) int64 {
+	if n < 0 {
+		return -((-2*n + d) / (2 * d))
+	}
+	return (2*n + d) / (2 * d)
+}
+
+func basisPoints(percent float64) int64 {
+	return int64(math.Round(percent * 100))
+}
+
+func ToCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func FromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
@@ -0,0 +1,207 @@
+package helper
+
+import (
+	"restaurant-management-backend/models"
+	"slices"
+	"testing"
+)
+
+func TestAllocateCents(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int64
+		weights []int64
+		want    []int64
+	}{
+		{"even thirds", 100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{"proportional", 101, []int64{3, 1}, []int64{76, 25}},
+		{"tie goes to the earlier weight", 1, []int64{50, 50}, []int64{1, 0}},
+		{"largest remainder wins", 2, []int64{1, 3, 6}, []int64{0, 1, 1}},
+		{"no weight shares evenly", 10, []int64{0, 0, 0}, []int64{4, 3, 3}},
+		{"negative weights count as none", 9, []int64{-5, 1, 2}, []int64{0, 3, 6}},
+		{"negative total", -100, []int64{1, 1, 1}, []int64{-34, -33, -33}},
+		{"zero total", 0, []int64{2, 1}, []int64{0, 0}},
+		{"no weights", 7, []int64{}, []int64{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := AllocateCents(test.total, test.weights)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("AllocateCents(%d, %v) = %v, want %v", test.total, test.weights, got, test.want)
+			}
+		})
+	}
+}
+
+func TestAllocateCentsSharesSumToTotal(t *testing.T) {
+	weights := [][]int64{{1}, {1, 2}, {7, 0, 3}, {333, 333, 334}, {1, 1, 1, 1, 1, 1, 1}, {0, 0}}
+	for _, w := range weights {
+		for total := int64(-250); total <= 250; total += 7 {
+			var sum int64
+			for _, share := range AllocateCents(total, w) {
+				sum += share
+			}
+			if sum != total {
+				t.Fatalf("AllocateCents(%d, %v) adds up to %d", total, w, sum)
+			}
+		}
+	}
+}
+
+func TestRoundDiv(t *testing.T) {
+	tests := []struct{ n, d, want int64 }{
+		{5, 2, 3},
+		{-5, 2, -3},
+		{4, 3, 1},
+		{-4, 3, -1},
+		{149, 100, 1},
+		{150, 100, 2},
+		{0, 7, 0},
+	}
+	for _, test := range tests {
+		if got := RoundDiv(test.n, test.d); got != test.want {
+			t.Errorf("RoundDiv(%d, %d) = %d, want %d", test.n, test.d, got, test.want)
+		}
+	}
+}
+
+func TestCalculateInvoiceTotals(t *testing.T) {
+	vat := models.AppliedTax{Tax_rate_id: "vat", Name: "VAT", Rate: 20, Is_inclusive: true}
+	sales := models.AppliedTax{Tax_rate_id: "sales", Name: "Sales tax", Rate: 10}
+	city := models.AppliedTax{Tax_rate_id: "city", Name: "City tax", Rate: 2.5}
+
+	tests := []struct {
+		name           string
+		orderItems     []models.OrderItem
+		orderDiscounts []models.Discount
+		wantNet        float64
+		wantInclusive  float64
+		wantExclusive  float64
+		wantTotal      float64
+		wantLineNets   []float64
+	}{
+		{
+			name:          "exclusive rate",
+			orderItems:    []models.OrderItem{orderItem("a", 10, nil, sales)},
+			wantNet:       10,
+			wantExclusive: 1,
+			wantTotal:     11,
+			wantLineNets:  []float64{10},
+		},
+		{
+			name:          "inclusive rate is part of the price",
+			orderItems:    []models.OrderItem{orderItem("a", 12, nil, vat)},
+			wantNet:       12,
+			wantInclusive: 2,
+			wantTotal:     12,
+			wantLineNets:  []float64{12},
+		},
+		{
+			name:          "inclusive and exclusive rates",
+			orderItems:    []models.OrderItem{orderItem("a", 12, nil, vat, sales)},
+			wantNet:       12,
+			wantInclusive: 2,
+			wantExclusive: 1,
+			wantTotal:     13,
+			wantLineNets:  []float64{12},
+		},
+		{
+			name:          "stacked exclusive rates round per line and rate",
+			orderItems:    []models.OrderItem{orderItem("a", 0.99, nil, sales, city), orderItem("b", 0.99, nil, sales, city)},
+			wantNet:       1.98,
+			wantExclusive: 0.24,
+			wantTotal:     2.22,
+			wantLineNets:  []float64{0.99, 0.99},
+		},
+		{
+			name: "line and stacked order discounts",
+			orderItems: []models.OrderItem{
+				orderItem("a", 10, discount("PERCENT", 10), sales),
+				orderItem("b", 5, nil, sales),
+			},
+			orderDiscounts: []models.Discount{*discount("PERCENT", 10), *discount("FIXED", 1)},
+			wantNet:        11.6,
+			wantExclusive:  1.16,
+			wantTotal:      12.76,
+			wantLineNets:   []float64{7.46, 4.14},
+		},
+		{
+			name:           "discounts never exceed what they apply to",
+			orderItems:     []models.OrderItem{orderItem("a", 5, discount("FIXED", 8), sales), orderItem("b", 3, nil, sales)},
+			orderDiscounts: []models.Discount{*discount("FIXED", 10)},
+			wantNet:        0,
+			wantTotal:      0,
+			wantLineNets:   []float64{0, 0},
+		},
+		{
+			name: "voided and comped items are not charged",
+			orderItems: []models.OrderItem{
+				orderItem("a", 10, nil, sales),
+				voided(orderItem("b", 4, nil, sales)),
+				comped(orderItem("c", 6, nil, sales)),
+			},
+			wantNet:       10,
+			wantExclusive: 1,
+			wantTotal:     11,
+			wantLineNets:  []float64{10},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			totals := CalculateInvoiceTotals(test.orderItems, test.orderDiscounts)
+			if totals.Net != test.wantNet || totals.Inclusive_tax != test.wantInclusive || totals.Exclusive_tax != test.wantExclusive || totals.Total != test.wantTotal {
+				t.Errorf("net %v, inclusive %v, exclusive %v, total %v; want %v, %v, %v, %v",
+					totals.Net, totals.Inclusive_tax, totals.Exclusive_tax, totals.Total,
+					test.wantNet, test.wantInclusive, test.wantExclusive, test.wantTotal)
+			}
+			lineNets := []float64{}
+			for _, line := range totals.Lines {
+				lineNets = append(lineNets, line.Net)
+			}
+			if !slices.Equal(lineNets, test.wantLineNets) {
+				t.Errorf("line nets = %v, want %v", lineNets, test.wantLineNets)
+			}
+			assertTotalsAddUp(t, totals)
+		})
+	}
+}
+
+// assertTotalsAddUp checks the lines and the rate breakdown sum to the totals.
+func assertTotalsAddUp(t *testing.T, totals InvoiceTotals) {
+	t.Helper()
+	var net, tax, total, byRate int64
+	for _, line := range totals.Lines {
+		net += ToCents(line.Net)
+		tax += ToCents(line.Tax)
+		total += ToCents(line.Total)
+	}
+	for _, rate := range totals.Taxes {
+		byRate += ToCents(rate.Amount)
+	}
+	if net != ToCents(totals.Net) || tax != ToCents(totals.Tax_total) || byRate != tax || total != ToCents(totals.Total) {
+		t.Errorf("lines add up to net %d, tax %d (%d by rate), total %d; totals are %v, %v, %v",
+			net, tax, byRate, total, totals.Net, totals.Tax_total, totals.Total)
+	}
+	if ToCents(totals.Subtotal)-ToCents(totals.Discount_total) != ToCents(totals.Net) {
+		t.Errorf("subtotal %v less discounts %v is not net %v", totals.Subtotal, totals.Discount_total, totals.Net)
+	}
+}
+
+func orderItem(id string, price float64, lineDiscount *models.Discount, taxes ...models.AppliedTax) models.OrderItem {
+	return models.OrderItem{Order_item_id: id, Unit_price: &price, Discount: lineDiscount, Taxes: taxes}
+}
+
+func discount(kind string, value float64) *models.Discount {
+	return &models.Discount{Kind: &kind, Value: &value}
+}
+
+func voided(orderItem models.OrderItem) models.OrderItem {
+	status := "VOIDED"
+	orderItem.Status = &status
+	return orderItem
+}
+
+func comped(orderItem models.OrderItem) models.OrderItem {
+	orderItem.Adjustment = &models.OrderItemAdjustment{Kind: "COMP"}
+	return orderItem
+}
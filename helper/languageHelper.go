@@ -0,0 +1,97 @@
+package helper
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var DEFAULT_LANGUAGE string = defaultLanguage()
+
+var languageTag = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+func defaultLanguage() string {
+	language := os.Getenv("DEFAULT_LANGUAGE")
+	if language == "" {
+		language = "en"
+	}
+	return language
+}
+
+// RequestLanguages lists the languages to look up translations in, most
+// preferred first: the "lang" query parameter, then the Accept-Language
+// header by quality. A regional tag such as "fr-CA" is followed by its base
+// language. Tags are lower-cased as they are case-insensitive. The list stops
+// at the default language, whose content is the untranslated fields.
+func RequestLanguages(lang string, acceptLanguage string) []string {
+	var candidates []string
+	if lang != "" {
+		candidates = append(candidates, lang)
+	}
+	candidates = append(candidates, parseAcceptLanguage(acceptLanguage)...)
+
+	var languages []string
+	seen := map[string]bool{}
+	for _, candidate := range candidates {
+		candidate = strings.ToLower(candidate)
+		for _, language := range []string{candidate, strings.Split(candidate, "-")[0]} {
+			if !languageTag.MatchString(language) {
+				continue
+			}
+			if strings.EqualFold(language, DEFAULT_LANGUAGE) {
+				return languages
+			}
+			if !seen[language] {
+				seen[language] = true
+				languages = append(languages, language)
+			}
+		}
+	}
+	return languages
+}
+
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		language string
+		quality  float64
+	}
+
+	var entries []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		language := strings.TrimSpace(fields[0])
+		if language == "" || language == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			entries = append(entries, weighted{language, quality})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].quality > entries[j].quality
+	})
+
+	var languages []string
+	for _, entry := range entries {
+		languages = append(languages, entry.language)
+	}
+	return languages
+}
+
+// IsLanguageTag reports whether tag can be used as a translation key.
+func IsLanguageTag(tag string) bool {
+	return languageTag.MatchString(tag)
+}
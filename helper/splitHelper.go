@@ -0,0 +1,132 @@
+package helper
+
+import "slices"
+
+// SplitTotalsByItems shares an order's totals out over parts, each paying for
+// the order items listed for it. A part's amounts are the sums of its lines
+// as priced on the whole order, and the service charge is shared in
+// proportion to the parts' net, so the parts always add up to the order to
+// the cent.
+func SplitTotalsByItems(totals InvoiceTotals, parts [][]string) []InvoiceTotals {
+	partTotals := make([]InvoiceTotals, len(parts))
+	nets := make([]int64, len(parts))
+	for i, orderItemIds := range parts {
+		var subtotal, lineDiscounts, orderDiscounts, net int64
+		var rates []InvoiceTax
+		lines := []InvoiceLine{}
+		for _, line := range totals.Lines {
+			if !slices.Contains(orderItemIds, line.Order_item_id) {
+				continue
+			}
+			lines = append(lines, line)
+			subtotal += ToCents(line.Gross)
+			lineDiscounts += ToCents(line.Discount)
+			orderDiscounts += ToCents(line.Order_discount)
+			net += ToCents(line.Net)
+			rates = addRateTaxes(rates, line.Taxes)
+		}
+		nets[i] = net
+
+		partTotals[i] = InvoiceTotals{
+			Lines:           lines,
+			Discounts:       []InvoiceDiscount{},
+			Taxes:           rates,
+			Subtotal:        FromCents(subtotal),
+			Line_discounts:  FromCents(lineDiscounts),
+			Order_discounts: FromCents(orderDiscounts),
+			Discount_total:  FromCents(lineDiscounts + orderDiscounts),
+			Net:             FromCents(net),
+		}
+	}
+
+	serviceCharges := AllocateCents(ToCents(totals.Service_charge), nets)
+	for i := range partTotals {
+		finishPart(&partTotals[i], serviceCharges[i])
+	}
+	return partTotals
+}
+
+// SplitTotalsEvenly shares an order's totals out over n equal parts. Each
+// amount is shared with AllocateCents, so where it does not divide evenly
+// the first parts pay a cent more and the parts add up to the order.
+func SplitTotalsEvenly(totals InvoiceTotals, n int) []InvoiceTotals {
+	weights := make([]int64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	share := func(amount float64) []int64 {
+		return AllocateCents(ToCents(amount), weights)
+	}
+
+	subtotals := share(totals.Subtotal)
+	lineDiscounts := share(totals.Line_discounts)
+	orderDiscounts := share(totals.Order_discounts)
+	serviceCharges := share(totals.Service_charge)
+	rateTaxables := make([][]int64, len(totals.Taxes))
+	rateAmounts := make([][]int64, len(totals.Taxes))
+	for j, tax := range totals.Taxes {
+		rateTaxables[j] = share(tax.Taxable)
+		rateAmounts[j] = share(tax.Amount)
+	}
+
+	partTotals := make([]InvoiceTotals, n)
+	for i := range partTotals {
+		rates := []InvoiceTax{}
+		for j, tax := range totals.Taxes {
+			tax.Taxable = FromCents(rateTaxables[j][i])
+			tax.Amount = FromCents(rateAmounts[j][i])
+			rates = append(rates, tax)
+		}
+		partTotals[i] = InvoiceTotals{
+			Lines:           []InvoiceLine{},
+			Discounts:       []InvoiceDiscount{},
+			Taxes:           rates,
+			Subtotal:        FromCents(subtotals[i]),
+			Line_discounts:  FromCents(lineDiscounts[i]),
+			Order_discounts: FromCents(orderDiscounts[i]),
+			Discount_total:  FromCents(lineDiscounts[i] + orderDiscounts[i]),
+			Net:             FromCents(subtotals[i] - lineDiscounts[i] - orderDiscounts[i]),
+		}
+		finishPart(&partTotals[i], serviceCharges[i])
+	}
+	return partTotals
+}
+
+// addRateTaxes adds line taxes to the per rate sums, keeping the order the
+// rates are first seen in.
+func addRateTaxes(rates []InvoiceTax, taxes []InvoiceTax) []InvoiceTax {
+	if rates == nil {
+		rates = []InvoiceTax{}
+	}
+	for _, tax := range taxes {
+		j := slices.IndexFunc(rates, func(rate InvoiceTax) bool { return rate.Tax_rate_id == tax.Tax_rate_id })
+		if j < 0 {
+			rates = append(rates, tax)
+			continue
+		}
+		rates[j].Taxable = FromCents(ToCents(rates[j].Taxable) + ToCents(tax.Taxable))
+		rates[j].Amount = FromCents(ToCents(rates[j].Amount) + ToCents(tax.Amount))
+	}
+	return rates
+}
+
+// finishPart works out a part's tax and totals from its net, rates and
+// service charge.
+func finishPart(part *InvoiceTotals, serviceCharge int64) {
+	var inclusive, exclusive int64
+	for _, tax := range part.Taxes {
+		if tax.Is_inclusive {
+			inclusive += ToCents(tax.Amount)
+		} else {
+			exclusive += ToCents(tax.Amount)
+		}
+	}
+	total := ToCents(part.Net) + exclusive + serviceCharge
+
+	part.Inclusive_tax = FromCents(inclusive)
+	part.Exclusive_tax = FromCents(exclusive)
+	part.Tax_total = FromCents(inclusive + exclusive)
+	part.Service_charge = FromCents(serviceCharge)
+	part.Total = FromCents(total)
+	part.Total_with_tips = FromCents(total)
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"log"
 	"os"
+	"time"
 
+	controller "restaurant-management-backend/controllers"
 	"restaurant-management-backend/database"
 	"restaurant-management-backend/middleware"
 	"restaurant-management-backend/routes"
+	"restaurant-management-backend/storage"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -20,17 +24,45 @@ func main() {
 		port = "8000"
 	}
 
+	if err := controller.EnsureFoodIndexes(); err != nil {
+		log.Println("warning: food indexes were not created, food search may not work:", err)
+	}
+	if err := controller.EnsureManager(); err != nil {
+		log.Println("warning: MANAGER_EMAIL was not made a manager:", err)
+	}
+	if err := controller.ConfigurePaymentGateway(); err != nil {
+		log.Fatal(err)
+	}
+	go controller.RunPriceScheduler(time.Minute)
+
 	router := gin.New()
 	router.Use(gin.Logger())
+	router.Static(storage.UPLOAD_URL, storage.UPLOAD_DIR)
 	routes.UserRoutes(router)
 	router.Use(middleware.Authentication())
 
+	routes.UserAdminRoutes(router)
 	routes.FoodRoutes(router)
 	routes.MenuRoutes(router)
+	routes.ComboRoutes(router)
+	routes.PricingRuleRoutes(router)
+	routes.PromoCodeRoutes(router)
+	routes.TaxRateRoutes(router)
+	routes.ServiceChargeRoutes(router)
 	routes.TableRoutes(router)
 	routes.OrderRoutes(router)
 	routes.OrderItemRoutes(router)
 	routes.InvoiceRoutes(router)
+	routes.KitchenRoutes(router)
+	routes.EventRoutes(router)
+	routes.IngredientRoutes(router)
+	routes.RecipeRoutes(router)
+	routes.InventoryRoutes(router)
+	routes.SupplierRoutes(router)
+	routes.PurchaseOrderRoutes(router)
+	routes.UploadRoutes(router)
+	routes.ReportRoutes(router)
+	routes.RefundRoutes(router)
 
 	router.Run(":" + port)
 }
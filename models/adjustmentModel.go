@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// VoidReasons are the reason codes for voiding an order item before it is prepared.
+var VoidReasons = []string{
+	"customer_changed_mind",
+	"ordered_by_mistake",
+	"entered_in_error",
+	"out_of_stock",
+	"duplicate",
+}
+
+// CompReasons are the reason codes for giving away an order item after it is prepared.
+var CompReasons = []string{
+	"quality_issue",
+	"wrong_item_served",
+	"long_wait",
+	"service_recovery",
+	"staff_meal",
+	"manager_courtesy",
+}
+
+func IsVoidReason(value string) bool {
+	return contains(VoidReasons, value)
+}
+
+func IsCompReason(value string) bool {
+	return contains(CompReasons, value)
+}
+
+// OrderItemAdjustment records an order item voided before it was prepared
+// or comped after; either way it is not charged. Amount is what it would
+// have cost.
+type OrderItemAdjustment struct {
+	Kind         string    `json:"kind"`
+	Reason_code  string    `json:"reason_code"`
+	Note         *string   `json:"note"`
+	Amount       float64   `json:"amount"`
+	Requested_by string    `json:"requested_by"`
+	Approved_by  *string   `json:"approved_by"`
+	Created_at   time.Time `json:"created_at"`
+}
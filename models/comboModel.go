@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Combo struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         *string            `json:"name" validate:"required,min=2,max=100"`
+	Description  *string            `json:"description" validate:"omitempty,max=500"`
+	Price        *float64           `json:"price" validate:"required"`
+	Tax_category *string            `json:"tax_category" validate:"omitempty,eq=FOOD|eq=ALCOHOL|eq=TAKEAWAY"`
+	Slots        []ComboSlot        `json:"slots" validate:"required,min=1,dive"`
+	Menu_id      *string            `json:"menu_id"`
+	Is_available *bool              `json:"is_available"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+	Combo_id     string             `json:"combo_id"`
+}
+
+// ComboSlot is one choice in a combo, e.g. "Drink", filled by exactly one of its foods.
+type ComboSlot struct {
+	Name     string   `json:"name" validate:"required"`
+	Food_ids []string `json:"food_ids" validate:"required,min=1"`
+}
@@ -0,0 +1,46 @@
+package models
+
+// Allergens is the controlled vocabulary of allergens that can be declared on a food.
+var Allergens = []string{
+	"gluten",
+	"crustaceans",
+	"eggs",
+	"fish",
+	"peanuts",
+	"soy",
+	"dairy",
+	"nuts",
+	"celery",
+	"mustard",
+	"sesame",
+	"sulphites",
+	"lupin",
+	"molluscs",
+}
+
+// DietaryTags is the controlled vocabulary of dietary tags that can be declared on a food.
+var DietaryTags = []string{
+	"vegan",
+	"vegetarian",
+	"halal",
+	"kosher",
+	"gluten_free",
+	"dairy_free",
+}
+
+func IsAllergen(value string) bool {
+	return contains(Allergens, value)
+}
+
+func IsDietaryTag(value string) bool {
+	return contains(DietaryTags, value)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
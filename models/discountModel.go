@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Discount takes a percentage or a fixed amount off an order item or an order.
+type Discount struct {
+	Discount_id   string    `json:"discount_id"`
+	Kind          *string   `json:"kind" validate:"required,eq=PERCENT|eq=FIXED"`
+	Value         *float64  `json:"value" validate:"required,gt=0"`
+	Reason        *string   `json:"reason" validate:"required,min=2,max=200"`
+	Promo_code    *string   `json:"promo_code"`
+	Authorized_by string    `json:"authorized_by"`
+	Created_at    time.Time `json:"created_at"`
+}
+
+type PromoCode struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Code          *string            `json:"code" validate:"required,alphanum,min=3,max=32"`
+	Description   *string            `json:"description" validate:"omitempty,max=200"`
+	Kind          *string            `json:"kind" validate:"required,eq=PERCENT|eq=FIXED"`
+	Value         *float64           `json:"value" validate:"required,gt=0"`
+	Max_uses      *int               `json:"max_uses" validate:"omitempty,min=1"`
+	Used_count    int                `json:"used_count"`
+	Valid_from    *time.Time         `json:"valid_from"`
+	Valid_until   *time.Time         `json:"valid_until"`
+	Is_active     *bool              `json:"is_active"`
+	Created_at    time.Time          `json:"created_at"`
+	Updated_at    time.Time          `json:"updated_at"`
+	Promo_code_id string             `json:"promo_code_id"`
+}
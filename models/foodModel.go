@@ -7,12 +7,24 @@ import (
 )
 
 type Food struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       *string            `json:"name" validate:"required,min=2,max=100"`
-	Price      *float64           `json:"price" validate:"required"`
-	Food_image *string            `json:"food_image" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Food_id    string             `json:"food_id"`
-	Menu_id    *string            `json:"menu_id" validate:"required"`
+	ID                 primitive.ObjectID         `bson:"_id"`
+	Name               *string                    `json:"name" validate:"required,min=2,max=100"`
+	Description        *string                    `json:"description" validate:"omitempty,max=500"`
+	Category           *string                    `json:"category"`
+	Tax_category       *string                    `json:"tax_category" validate:"omitempty,eq=FOOD|eq=ALCOHOL|eq=TAKEAWAY"`
+	Station            *string                    `json:"station" validate:"omitempty,station"`
+	Price              *float64                   `json:"price" validate:"required"`
+	Food_image         *string                    `json:"food_image"`
+	Food_thumbnail     *string                    `json:"food_thumbnail"`
+	Created_at         time.Time                  `json:"created_at"`
+	Updated_at         time.Time                  `json:"updated_at"`
+	Food_id            string                     `json:"food_id"`
+	Menu_id            *string                    `json:"menu_id" validate:"required"`
+	Is_available       *bool                      `json:"is_available"`
+	Unavailable_reason *string                    `json:"unavailable_reason"`
+	Display_position   *int                       `json:"display_position" validate:"omitempty,min=0"`
+	Allergens          []string                   `json:"allergens" validate:"omitempty,dive,allergen"`
+	Dietary_tags       []string                   `json:"dietary_tags" validate:"omitempty,dive,dietary_tag"`
+	Nutrition          []NutritionFacts           `json:"nutrition" validate:"omitempty,dive"`
+	Translations       map[string]FoodTranslation `json:"translations" validate:"omitempty,dive,keys,language_tag,endkeys"`
 }
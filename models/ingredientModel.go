@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Ingredient struct {
+	ID                  primitive.ObjectID `bson:"_id"`
+	Name                *string            `json:"name" validate:"required,min=2,max=100"`
+	Unit                *string            `json:"unit" validate:"required,eq=g|eq=kg|eq=ml|eq=l|eq=pcs"`
+	On_hand             *float64           `json:"on_hand"`
+	Low_stock_threshold *float64           `json:"low_stock_threshold" validate:"omitempty,min=0"`
+	Auto_disable_foods  *bool              `json:"auto_disable_foods"`
+	Par_level           *float64           `json:"par_level" validate:"omitempty,min=0"`
+	Supplier_id         *string            `json:"supplier_id"`
+	Created_at          time.Time          `json:"created_at"`
+	Updated_at          time.Time          `json:"updated_at"`
+	Ingredient_id       string             `json:"ingredient_id"`
+}
@@ -7,12 +7,26 @@ import (
 )
 
 type Invoice struct {
-	ID               primitive.ObjectID `bson:"_id"`
-	Invoice_id       string             `json:"invoice_id"`
-	Order_id         string             `json:"order_id"`
-	Payment_method   *string            `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
-	Payment_status   *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID"`
-	Payment_due_date time.Time          `json:"Payment_due_date"`
-	Created_at       time.Time          `json:"created_at"`
-	Updated_at       time.Time          `json:"updated_at"`
+	ID               primitive.ObjectID    `bson:"_id"`
+	Invoice_id       string                `json:"invoice_id"`
+	Order_id         string                `json:"order_id"`
+	Payment_method   *string               `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
+	Payment_status   *string               `json:"payment_status" validate:"required,eq=PENDING|eq=PARTIALLY_PAID|eq=PAID"`
+	Payment_due_date time.Time             `json:"Payment_due_date"`
+	Service_charge   *AppliedServiceCharge `json:"service_charge"`
+	Tips             []Tip                 `json:"tips"`
+	Split            *InvoiceSplit         `json:"split"`
+	Created_at       time.Time             `json:"created_at"`
+	Updated_at       time.Time             `json:"updated_at"`
+}
+
+// InvoiceSplit is the part of its order an invoice bills when the order is
+// split: the order items (of the seats) it pays for, or an even share.
+type InvoiceSplit struct {
+	Split_id       string   `json:"split_id"`
+	Mode           string   `json:"mode"`
+	Part           int      `json:"part"`
+	Parts          int      `json:"parts"`
+	Order_item_ids []string `json:"order_item_ids"`
+	Seats          []int    `json:"seats"`
 }
@@ -0,0 +1,13 @@
+package models
+
+// Stations are the kitchen stations foods are prepared at.
+var Stations = []string{
+	"grill",
+	"fryer",
+	"bar",
+	"cold",
+}
+
+func IsStation(value string) bool {
+	return contains(Stations, value)
+}
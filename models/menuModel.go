@@ -7,12 +7,13 @@ import (
 )
 
 type Menu struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       string             `json:"name" validate:"required"`
-	Category   string             `json:"category" validate:"required"`
-	Start_Date *time.Time         `json:"start_date"`
-	End_Date   *time.Time         `json:"end_date"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Menu_id    string             `json:"food_id"`
+	ID           primitive.ObjectID         `bson:"_id"`
+	Name         string                     `json:"name" validate:"required"`
+	Category     string                     `json:"category" validate:"required"`
+	Start_Date   *time.Time                 `json:"start_date"`
+	End_Date     *time.Time                 `json:"end_date"`
+	Created_at   time.Time                  `json:"created_at"`
+	Updated_at   time.Time                  `json:"updated_at"`
+	Menu_id      string                     `json:"food_id"`
+	Translations map[string]MenuTranslation `json:"translations" validate:"omitempty,dive,keys,language_tag,endkeys"`
 }
@@ -0,0 +1,15 @@
+package models
+
+// NutritionFacts are per serving. Facts without a size apply to every size
+// that has no facts of its own.
+type NutritionFacts struct {
+	Size            *string `json:"size" validate:"omitempty,eq=S|eq=M|eq=L"`
+	Calories        float64 `json:"calories" validate:"min=0,max=5000"`
+	Protein_g       float64 `json:"protein_g" validate:"min=0,max=500"`
+	Carbohydrates_g float64 `json:"carbohydrates_g" validate:"min=0,max=1000"`
+	Sugar_g         float64 `json:"sugar_g" validate:"min=0,max=1000"`
+	Fat_g           float64 `json:"fat_g" validate:"min=0,max=500"`
+	Saturated_fat_g float64 `json:"saturated_fat_g" validate:"min=0,max=500"`
+	Fiber_g         float64 `json:"fiber_g" validate:"min=0,max=200"`
+	Sodium_mg       float64 `json:"sodium_mg" validate:"min=0,max=20000"`
+}
@@ -1,18 +1,67 @@
 package models
 
 import (
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type OrderItem struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	Quantity      *string            `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
-	Unit_price    *float64           `json:"unit_price" validate:"required"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	Food_id       *string            `json:"food_id" validate:"required"`
-	Order_item_id string             `json:"order_item_id"`
-	Order_id      string             `json:"order_id" validate:"required"`
+	ID              primitive.ObjectID   `bson:"_id"`
+	Quantity        *string              `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
+	Course          *int                 `json:"course" validate:"omitempty,min=1,max=10"`
+	Seat            *int                 `json:"seat" validate:"omitempty,min=1"`
+	Unit_price      *float64             `json:"unit_price"`
+	Base_price      *float64             `json:"base_price"`
+	Pricing_rule    *AppliedPricingRule  `json:"pricing_rule"`
+	Discount        *Discount            `json:"discount"`
+	Adjustment      *OrderItemAdjustment `json:"adjustment"`
+	Tax_category    *string              `json:"tax_category"`
+	Taxes           []AppliedTax         `json:"taxes"`
+	Created_at      time.Time            `json:"created_at"`
+	Updated_at      time.Time            `json:"updated_at"`
+	Food_id         *string              `json:"food_id" validate:"required_without=Combo_id"`
+	Combo_id        *string              `json:"combo_id"`
+	Components      []OrderItemComponent `json:"components" validate:"omitempty,dive"`
+	Bumped_stations []string             `json:"bumped_stations"`
+	Order_item_id   string               `json:"order_item_id"`
+	Order_id        string               `json:"order_id" validate:"required"`
+	Status          *string              `json:"status"`
+	Status_history  []OrderStatusChange  `json:"status_history"`
+}
+
+// OrderItemComponent is the food chosen for one slot of a combo order item.
+type OrderItemComponent struct {
+	Slot    string `json:"slot" validate:"required"`
+	Food_id string `json:"food_id" validate:"required"`
+}
+
+// OrderItemTransitions lists the statuses an order item may move to from each
+// status as it goes through the kitchen. Held items wait for their course to
+// be fired, a ready item can be sent back to preparing; served and voided
+// items are done.
+var OrderItemTransitions = map[string][]string{
+	"PENDING":   {"FIRED", "HELD", "VOIDED"},
+	"HELD":      {"FIRED", "PENDING", "VOIDED"},
+	"FIRED":     {"PREPARING", "READY", "VOIDED"},
+	"PREPARING": {"READY", "VOIDED"},
+	"READY":     {"SERVED", "PREPARING", "VOIDED"},
+	"SERVED":    {},
+	"VOIDED":    {},
+}
+
+// OrderItemEditable reports whether an order item in status can still be
+// changed, i.e. it has not been fired yet.
+func OrderItemEditable(status string) bool {
+	return status == "PENDING" || status == "HELD"
+}
+
+func CanTransitionOrderItem(from, to string) bool {
+	return slices.Contains(OrderItemTransitions[from], to)
+}
+
+// OrderItemInKitchen reports whether an order item in status is being made.
+func OrderItemInKitchen(status string) bool {
+	return status == "FIRED" || status == "PREPARING" || status == "READY"
 }
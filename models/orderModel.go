@@ -1,16 +1,57 @@
 package models
 
 import (
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Order struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Order_Date time.Time          `json:"order_date" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Order_id   string             `json:"order_id"`
-	Table_id   *string            `json:"table_id" validate:"required"`
+	ID             primitive.ObjectID  `bson:"_id"`
+	Order_Date     time.Time           `json:"order_date" validate:"required"`
+	Created_at     time.Time           `json:"created_at"`
+	Updated_at     time.Time           `json:"updated_at"`
+	Order_id       string              `json:"order_id"`
+	Table_id       *string             `json:"table_id" validate:"required"`
+	Discounts      []Discount          `json:"discounts"`
+	Status         *string             `json:"status"`
+	Status_history []OrderStatusChange `json:"status_history"`
+}
+
+// OrderStatusChange records who moved an order or order item to a status and when.
+type OrderStatusChange struct {
+	From       *string   `json:"from"`
+	To         string    `json:"to"`
+	Reason     *string   `json:"reason"`
+	Changed_by string    `json:"changed_by"`
+	Changed_at time.Time `json:"changed_at"`
+}
+
+// OrderTransitions lists the statuses an order may move to from each status.
+// A served order goes back to the kitchen when more is ordered and a billed
+// one back to served when the bill is reopened; paid and cancelled orders
+// are closed.
+var OrderTransitions = map[string][]string{
+	"OPEN":            {"SENT_TO_KITCHEN", "CANCELLED"},
+	"SENT_TO_KITCHEN": {"SERVED", "CANCELLED"},
+	"SERVED":          {"BILLED", "SENT_TO_KITCHEN"},
+	"BILLED":          {"PAID", "SERVED"},
+	"PAID":            {},
+	"CANCELLED":       {},
+}
+
+func CanTransitionOrder(from, to string) bool {
+	return slices.Contains(OrderTransitions[from], to)
+}
+
+// OrderAcceptsItems reports whether items may be added to or changed on an
+// order in status.
+func OrderAcceptsItems(status string) bool {
+	return status == "OPEN" || status == "SENT_TO_KITCHEN" || status == "SERVED"
+}
+
+// OrderIsClosed reports whether an order in status can no longer change.
+func OrderIsClosed(status string) bool {
+	return status == "PAID" || status == "CANCELLED"
 }
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Payment is an amount paid towards an invoice, which can be paid with
+// several payments of different tenders. Cash payments record the cash
+// tendered and the change given back; card payments are captured through the
+// payment gateway with the card token, which is not stored, and record the
+// gateway's reference.
+type Payment struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Invoice_id      string             `json:"invoice_id"`
+	Order_id        string             `json:"order_id"`
+	Tender          *string            `json:"tender" validate:"required,eq=CASH|eq=CARD|eq=GIFT_CARD"`
+	Amount          *float64           `json:"amount" validate:"required,gt=0"`
+	Tendered        *float64           `json:"tendered" validate:"omitempty,gt=0"`
+	Change          float64            `json:"change"`
+	Card_token      string             `json:"card_token,omitempty" bson:"-" validate:"required_if=Tender CARD"`
+	Reference       *string            `json:"reference" validate:"omitempty,max=100"`
+	Gateway         string             `json:"gateway,omitempty"`
+	Idempotency_key *string            `json:"idempotency_key,omitempty"`
+	Recorded_by     string             `json:"recorded_by"`
+	Created_at      time.Time          `json:"created_at"`
+	Payment_id      string             `json:"payment_id"`
+}
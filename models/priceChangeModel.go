@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PriceChange is one entry in a food's price history. Scheduled changes are
+// PENDING until their effective time, when they are APPLIED to the food.
+type PriceChange struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Food_id         string             `json:"food_id"`
+	Price           *float64           `json:"price" validate:"required,gte=0"`
+	Previous_price  *float64           `json:"previous_price"`
+	Effective_at    *time.Time         `json:"effective_at" validate:"required"`
+	Status          string             `json:"status" validate:"eq=PENDING|eq=APPLIED|eq=CANCELLED"`
+	Applied_at      *time.Time         `json:"applied_at"`
+	Created_by      string             `json:"created_by"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Price_change_id string             `json:"price_change_id"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PricingRule discounts the foods it targets during its time window, e.g.
+// 20% off drinks 17:00-19:00 on weekdays. A rule without targets applies to
+// every food, without days to every day and without times to the whole day.
+// Days are 0 (Sunday) to 6 (Saturday); an end time before the start time
+// runs past midnight.
+type PricingRule struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Name            *string            `json:"name" validate:"required,min=2,max=100"`
+	Kind            *string            `json:"kind" validate:"required,eq=PERCENT_OFF|eq=AMOUNT_OFF|eq=FIXED_PRICE"`
+	Value           *float64           `json:"value" validate:"required,gte=0"`
+	Days            []int              `json:"days" validate:"omitempty,dive,min=0,max=6"`
+	Start_time      *string            `json:"start_time" validate:"omitempty,datetime=15:04"`
+	End_time        *string            `json:"end_time" validate:"omitempty,datetime=15:04"`
+	Food_ids        []string           `json:"food_ids"`
+	Menu_ids        []string           `json:"menu_ids"`
+	Categories      []string           `json:"categories"`
+	Valid_from      *time.Time         `json:"valid_from"`
+	Valid_until     *time.Time         `json:"valid_until"`
+	Is_active       *bool              `json:"is_active"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Pricing_rule_id string             `json:"pricing_rule_id"`
+}
+
+// AppliedPricingRule records the rule that priced an order item.
+type AppliedPricingRule struct {
+	Pricing_rule_id string  `json:"pricing_rule_id"`
+	Name            string  `json:"name"`
+	Discount        float64 `json:"discount"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PurchaseOrder struct {
+	ID                primitive.ObjectID  `bson:"_id"`
+	Supplier_id       *string             `json:"supplier_id" validate:"required"`
+	Status            string              `json:"status" validate:"eq=DRAFT|eq=SENT|eq=PARTIALLY_RECEIVED|eq=RECEIVED|eq=CANCELLED"`
+	Lines             []PurchaseOrderLine `json:"lines" validate:"required,min=1,dive"`
+	Expected_date     *time.Time          `json:"expected_date"`
+	Note              *string             `json:"note" validate:"omitempty,max=500"`
+	Created_by        string              `json:"created_by"`
+	Sent_at           *time.Time          `json:"sent_at"`
+	Received_at       *time.Time          `json:"received_at"`
+	Created_at        time.Time           `json:"created_at"`
+	Updated_at        time.Time           `json:"updated_at"`
+	Purchase_order_id string              `json:"purchase_order_id"`
+}
+
+type PurchaseOrderLine struct {
+	Ingredient_id     string   `json:"ingredient_id" validate:"required"`
+	Quantity          float64  `json:"quantity" validate:"required,gt=0"`
+	Unit_cost         *float64 `json:"unit_cost" validate:"omitempty,gte=0"`
+	Received_quantity float64  `json:"received_quantity"`
+}
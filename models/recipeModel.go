@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Recipe lists the ingredients used by one serving of a food. A recipe
+// without a size applies to every size that has no recipe of its own.
+type Recipe struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Food_id    *string            `json:"food_id" validate:"required"`
+	Size       *string            `json:"size" validate:"omitempty,eq=S|eq=M|eq=L"`
+	Lines      []RecipeLine       `json:"lines" validate:"required,min=1,dive"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+	Recipe_id  string             `json:"recipe_id"`
+}
+
+type RecipeLine struct {
+	Ingredient_id string  `json:"ingredient_id" validate:"required"`
+	Amount        float64 `json:"amount" validate:"required,gt=0"`
+}
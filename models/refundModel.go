@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefundReasons are the reason codes for refunding a payment.
+var RefundReasons = []string{
+	"customer_complaint",
+	"overcharged",
+	"duplicate_payment",
+	"item_not_received",
+	"service_recovery",
+}
+
+func IsRefundReason(value string) bool {
+	return contains(RefundReasons, value)
+}
+
+// Refund gives back part or all of a payment of a paid invoice, approved by
+// a manager. Card refunds go through the payment gateway and record its
+// reference. Every refund is documented by a credit note.
+type Refund struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Refund_id         string             `json:"refund_id"`
+	Payment_id        string             `json:"payment_id"`
+	Invoice_id        string             `json:"invoice_id"`
+	Order_id          string             `json:"order_id"`
+	Tender            string             `json:"tender"`
+	Amount            float64            `json:"amount"`
+	Reason_code       string             `json:"reason_code"`
+	Note              *string            `json:"note"`
+	Gateway_reference *string            `json:"gateway_reference"`
+	Credit_note_id    string             `json:"credit_note_id"`
+	Requested_by      string             `json:"requested_by"`
+	Approved_by       string             `json:"approved_by"`
+	Created_at        time.Time          `json:"created_at"`
+}
+
+// CreditNote is the document correcting a paid invoice by the amount refunded.
+type CreditNote struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Credit_note_id string             `json:"credit_note_id"`
+	Invoice_id     string             `json:"invoice_id"`
+	Order_id       string             `json:"order_id"`
+	Refund_id      string             `json:"refund_id"`
+	Amount         float64            `json:"amount"`
+	Reason_code    string             `json:"reason_code"`
+	Note           *string            `json:"note"`
+	Issued_by      string             `json:"issued_by"`
+	Created_at     time.Time          `json:"created_at"`
+}
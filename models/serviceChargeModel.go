@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ServiceChargeRule adds a charge to the invoices of parties of at least
+// Min_guests. When several rules match, the one for the largest party wins.
+type ServiceChargeRule struct {
+	ID                     primitive.ObjectID `bson:"_id"`
+	Name                   *string            `json:"name" validate:"required,min=2,max=100"`
+	Kind                   *string            `json:"kind" validate:"required,eq=PERCENT|eq=FIXED"`
+	Value                  *float64           `json:"value" validate:"required,gt=0"`
+	Min_guests             *int               `json:"min_guests" validate:"required,min=1"`
+	Is_active              *bool              `json:"is_active"`
+	Created_at             time.Time          `json:"created_at"`
+	Updated_at             time.Time          `json:"updated_at"`
+	Service_charge_rule_id string             `json:"service_charge_rule_id"`
+}
+
+// AppliedServiceCharge records the rule charged on an invoice.
+type AppliedServiceCharge struct {
+	Service_charge_rule_id string  `json:"service_charge_rule_id"`
+	Name                   string  `json:"name"`
+	Kind                   string  `json:"kind"`
+	Value                  float64 `json:"value"`
+	Guests                 int     `json:"guests"`
+}
+
+// Tip is left on an invoice at payment and allocated to a staff member.
+type Tip struct {
+	Tip_id         string    `json:"tip_id"`
+	Amount         *float64  `json:"amount" validate:"required,gt=0"`
+	Staff_id       *string   `json:"staff_id"`
+	Payment_method *string   `json:"payment_method" validate:"required,eq=CARD|eq=CASH"`
+	Recorded_by    string    `json:"recorded_by"`
+	Created_at     time.Time `json:"created_at"`
+}
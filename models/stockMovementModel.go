@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StockMovement records one change to an ingredient's quantity on hand.
+// Change is the signed effect on on_hand; counts also record the expected
+// quantity before the count and the variance against it.
+type StockMovement struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Ingredient_id *string            `json:"ingredient_id" validate:"required"`
+	Type          string             `json:"type" validate:"eq=RECEIPT|eq=COUNT|eq=WASTE|eq=SALE"`
+	Quantity      *float64           `json:"quantity" validate:"required,gte=0"`
+	Change        float64            `json:"change"`
+	Expected      *float64           `json:"expected"`
+	Variance      *float64           `json:"variance"`
+	Reason        *string            `json:"reason"`
+	Note          *string            `json:"note" validate:"omitempty,max=500"`
+	Unit_cost     *float64           `json:"unit_cost" validate:"omitempty,gte=0"`
+	Reference     *string            `json:"reference"`
+	Recorded_by   string             `json:"recorded_by"`
+	Recorded_at   time.Time          `json:"recorded_at"`
+	Created_at    time.Time          `json:"created_at"`
+	Movement_id   string             `json:"movement_id"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Supplier struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Name           *string            `json:"name" validate:"required,min=2,max=100"`
+	Email          *string            `json:"email" validate:"omitempty,email"`
+	Phone          *string            `json:"phone"`
+	Lead_time_days *int               `json:"lead_time_days" validate:"omitempty,min=0"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	Supplier_id    string             `json:"supplier_id"`
+}
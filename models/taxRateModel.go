@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TaxRate is a percentage charged on the foods of a tax category. Inclusive
+// rates are already part of the menu price, exclusive rates are added on top.
+// A category can have several active rates, e.g. a state and a city tax.
+type TaxRate struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         *string            `json:"name" validate:"required,min=2,max=100"`
+	Tax_category *string            `json:"tax_category" validate:"required,eq=FOOD|eq=ALCOHOL|eq=TAKEAWAY"`
+	Rate         *float64           `json:"rate" validate:"required,gte=0,lte=100"`
+	Is_inclusive *bool              `json:"is_inclusive" validate:"required"`
+	Is_active    *bool              `json:"is_active"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+	Tax_rate_id  string             `json:"tax_rate_id"`
+}
+
+// AppliedTax records a tax rate in effect when an order item was ordered.
+type AppliedTax struct {
+	Tax_rate_id  string  `json:"tax_rate_id"`
+	Name         string  `json:"name"`
+	Rate         float64 `json:"rate"`
+	Is_inclusive bool    `json:"is_inclusive"`
+}
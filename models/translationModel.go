@@ -0,0 +1,11 @@
+package models
+
+type FoodTranslation struct {
+	Name        *string `json:"name" validate:"omitempty,min=2,max=100"`
+	Description *string `json:"description" validate:"omitempty,max=500"`
+}
+
+type MenuTranslation struct {
+	Name     *string `json:"name"`
+	Category *string `json:"category"`
+}
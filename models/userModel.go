@@ -7,16 +7,18 @@ import (
 )
 
 type User struct {
-	ID            primitive.ObjectID `bson:"_id"`
-	First_name    *string            `json:"first_name" validate:"required,min=2,max=100"`
-	Last_name     *string            `json:"last_name" validate:"required,min=2,max=100"`
-	Password      *string            `json:"Password" validate:"required,min=6"`
-	Email         *string            `json:"email" validate:"email,required"`
-	Avatar        *string            `json:"avatar"`
-	Phone         *string            `json:"phone" validate:"required"`
-	Token         *string            `json:"token"`
-	Refresh_Token *string            `json:"refresh_token"`
-	Created_at    time.Time          `json:"created_at"`
-	Updated_at    time.Time          `json:"updated_at"`
-	User_id       string             `json:"user_id"`
+	ID               primitive.ObjectID `bson:"_id"`
+	First_name       *string            `json:"first_name" validate:"required,min=2,max=100"`
+	Last_name        *string            `json:"last_name" validate:"required,min=2,max=100"`
+	Password         *string            `json:"Password" validate:"required,min=6"`
+	Email            *string            `json:"email" validate:"email,required"`
+	Avatar           *string            `json:"avatar"`
+	Avatar_thumbnail *string            `json:"avatar_thumbnail"`
+	Phone            *string            `json:"phone" validate:"required"`
+	Role             *string            `json:"role"`
+	Token            *string            `json:"token"`
+	Refresh_Token    *string            `json:"refresh_token"`
+	Created_at       time.Time          `json:"created_at"`
+	Updated_at       time.Time          `json:"updated_at"`
+	User_id          string             `json:"user_id"`
 }
@@ -0,0 +1,106 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FakeDeclinedToken is the card token the FakeGateway declines; every other
+// token is approved.
+const FakeDeclinedToken = "tok_declined"
+
+// FakeGateway is an in-process PaymentGateway for development. It is
+// deterministic: references are numbered in the order payments are
+// authorized and the card token alone decides whether a card is declined.
+type FakeGateway struct {
+	mu           sync.Mutex
+	sequence     int
+	payments     map[string]*fakePayment
+	idempotency  map[string]string
+	refundNumber int
+}
+
+type fakePayment struct {
+	authorized int64
+	captured   int64
+	refunded   int64
+	voided     bool
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{payments: map[string]*fakePayment{}, idempotency: map[string]string{}}
+}
+
+func (g *FakeGateway) Name() string {
+	return "fake"
+}
+
+func (g *FakeGateway) Authorize(ctx context.Context, request AuthorizeRequest) (Authorization, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if reference, ok := g.idempotency[request.Idempotency_key]; ok && request.Idempotency_key != "" {
+		return Authorization{Reference: reference, Amount: g.payments[reference].authorized}, nil
+	}
+	if request.Amount <= 0 {
+		return Authorization{}, fmt.Errorf("amount must be positive")
+	}
+	if request.Card_token == FakeDeclinedToken {
+		return Authorization{}, ErrDeclined
+	}
+
+	g.sequence++
+	reference := fmt.Sprintf("fake_auth_%06d", g.sequence)
+	g.payments[reference] = &fakePayment{authorized: request.Amount}
+	if request.Idempotency_key != "" {
+		g.idempotency[request.Idempotency_key] = reference
+	}
+	return Authorization{Reference: reference, Amount: request.Amount}, nil
+}
+
+func (g *FakeGateway) Capture(ctx context.Context, reference string, amount int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, ok := g.payments[reference]
+	if !ok {
+		return ErrNotFound
+	}
+	if payment.voided || payment.captured > 0 || amount <= 0 || amount > payment.authorized {
+		return ErrInvalidOperation
+	}
+	payment.captured = amount
+	return nil
+}
+
+func (g *FakeGateway) Void(ctx context.Context, reference string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, ok := g.payments[reference]
+	if !ok {
+		return ErrNotFound
+	}
+	if payment.voided || payment.captured > 0 {
+		return ErrInvalidOperation
+	}
+	payment.voided = true
+	return nil
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, reference string, amount int64) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, ok := g.payments[reference]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if amount <= 0 || amount > payment.captured-payment.refunded {
+		return "", ErrInvalidOperation
+	}
+	payment.refunded += amount
+	g.refundNumber++
+	return fmt.Sprintf("fake_refund_%06d", g.refundNumber), nil
+}
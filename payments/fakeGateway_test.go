@@ -0,0 +1,100 @@
+package payments
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFakeGatewayCaptureAndRefund(t *testing.T) {
+	ctx := context.Background()
+	gateway := NewFakeGateway()
+
+	authorization, err := gateway.Authorize(ctx, AuthorizeRequest{Amount: 2500, Card_token: "tok_visa", Idempotency_key: "key-1"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if authorization.Reference != "fake_auth_000001" || authorization.Amount != 2500 {
+		t.Fatalf("Authorize = %+v, want fake_auth_000001 for 2500", authorization)
+	}
+
+	if err := gateway.Capture(ctx, authorization.Reference, 3000); err != ErrInvalidOperation {
+		t.Errorf("Capture more than authorized = %v, want ErrInvalidOperation", err)
+	}
+	if err := gateway.Capture(ctx, authorization.Reference, 2500); err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if err := gateway.Capture(ctx, authorization.Reference, 2500); err != ErrInvalidOperation {
+		t.Errorf("Capture twice = %v, want ErrInvalidOperation", err)
+	}
+	if err := gateway.Void(ctx, authorization.Reference); err != ErrInvalidOperation {
+		t.Errorf("Void after capture = %v, want ErrInvalidOperation", err)
+	}
+
+	refund, err := gateway.Refund(ctx, authorization.Reference, 1000)
+	if err != nil || refund != "fake_refund_000001" {
+		t.Fatalf("Refund = %q, %v, want fake_refund_000001", refund, err)
+	}
+	if _, err := gateway.Refund(ctx, authorization.Reference, 1501); err != ErrInvalidOperation {
+		t.Errorf("Refund more than is left = %v, want ErrInvalidOperation", err)
+	}
+	if refund, err := gateway.Refund(ctx, authorization.Reference, 1500); err != nil || refund != "fake_refund_000002" {
+		t.Errorf("Refund the rest = %q, %v, want fake_refund_000002", refund, err)
+	}
+}
+
+func TestFakeGatewayVoid(t *testing.T) {
+	ctx := context.Background()
+	gateway := NewFakeGateway()
+
+	authorization, err := gateway.Authorize(ctx, AuthorizeRequest{Amount: 1000, Card_token: "tok_visa"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if err := gateway.Void(ctx, authorization.Reference); err != nil {
+		t.Fatalf("Void: %v", err)
+	}
+	if err := gateway.Capture(ctx, authorization.Reference, 1000); err != ErrInvalidOperation {
+		t.Errorf("Capture after void = %v, want ErrInvalidOperation", err)
+	}
+	if _, err := gateway.Refund(ctx, authorization.Reference, 1000); err != ErrInvalidOperation {
+		t.Errorf("Refund after void = %v, want ErrInvalidOperation", err)
+	}
+	if err := gateway.Void(ctx, "fake_auth_999999"); err != ErrNotFound {
+		t.Errorf("Void unknown = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFakeGatewayDecline(t *testing.T) {
+	gateway := NewFakeGateway()
+
+	if _, err := gateway.Authorize(context.Background(), AuthorizeRequest{Amount: 1000, Card_token: FakeDeclinedToken}); err != ErrDeclined {
+		t.Fatalf("Authorize declined token = %v, want ErrDeclined", err)
+	}
+	// a declined card does not use up a reference
+	authorization, err := gateway.Authorize(context.Background(), AuthorizeRequest{Amount: 1000, Card_token: "tok_visa"})
+	if err != nil || authorization.Reference != "fake_auth_000001" {
+		t.Errorf("Authorize after decline = %+v, %v, want fake_auth_000001", authorization, err)
+	}
+}
+
+func TestFakeGatewayIdempotencyReplay(t *testing.T) {
+	ctx := context.Background()
+	gateway := NewFakeGateway()
+
+	first, err := gateway.Authorize(ctx, AuthorizeRequest{Amount: 1200, Card_token: "tok_visa", Idempotency_key: "retry"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	replay, err := gateway.Authorize(ctx, AuthorizeRequest{Amount: 9900, Card_token: "tok_visa", Idempotency_key: "retry"})
+	if err != nil {
+		t.Fatalf("Authorize replay: %v", err)
+	}
+	if replay != first {
+		t.Errorf("Authorize replay = %+v, want %+v", replay, first)
+	}
+
+	other, err := gateway.Authorize(ctx, AuthorizeRequest{Amount: 1200, Card_token: "tok_visa", Idempotency_key: "other"})
+	if err != nil || other.Reference == first.Reference {
+		t.Errorf("Authorize with another key = %+v, %v, want a new reference", other, err)
+	}
+}
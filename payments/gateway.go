@@ -0,0 +1,57 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// PaymentGateway takes card payments. A payment is authorized for an amount,
+// then captured, or voided when it is not taken after all; captured payments
+// can be refunded. Amounts are in cents.
+type PaymentGateway interface {
+	Name() string
+	Authorize(ctx context.Context, request AuthorizeRequest) (Authorization, error)
+	Capture(ctx context.Context, reference string, amount int64) error
+	Void(ctx context.Context, reference string) error
+	Refund(ctx context.Context, reference string, amount int64) (refundReference string, err error)
+}
+
+// AuthorizeRequest authorizes an amount on the card the token stands for.
+// Authorizing again with the same idempotency key returns the same authorization.
+type AuthorizeRequest struct {
+	Amount          int64
+	Card_token      string
+	Idempotency_key string
+}
+
+// Authorization is an amount held on a card until it is captured or voided.
+type Authorization struct {
+	Reference string
+	Amount    int64
+}
+
+var (
+	ErrDeclined         = errors.New("card was declined")
+	ErrNotFound         = errors.New("payment was not found at the gateway")
+	ErrInvalidOperation = errors.New("payment gateway operation is not allowed in the payment's state")
+)
+
+// FromEnv returns the gateway named by PAYMENT_GATEWAY, or nil when none is
+// configured and cards cannot be taken. The fake gateway approves cards
+// without charging them and forgets its payments on restart, so it is only
+// used in development, with PAYMENT_GATEWAY_DEV_MODE=true.
+func FromEnv() (PaymentGateway, error) {
+	switch name := os.Getenv("PAYMENT_GATEWAY"); name {
+	case "":
+		return nil, nil
+	case "fake":
+		if os.Getenv("PAYMENT_GATEWAY_DEV_MODE") != "true" {
+			return nil, fmt.Errorf("the fake payment gateway does not charge cards, set PAYMENT_GATEWAY_DEV_MODE=true to use it in development")
+		}
+		return NewFakeGateway(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment gateway: %s", name)
+	}
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ComboRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/combos", controller.GetCombos())
+	incomingRoutes.GET("/combos/:combo_id", controller.GetCombo())
+	incomingRoutes.POST("/combos", controller.CreateCombo())
+	incomingRoutes.PATCH("/combos/:combo_id", controller.UpdateCombo())
+}
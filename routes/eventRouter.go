@@ -0,0 +1,11 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func EventRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/events", controller.StreamEvents())
+}
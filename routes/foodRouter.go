@@ -11,4 +11,7 @@ func FoodRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/foods/:food_id", controller.GetFood())
 	incomingRoutes.POST("/foods", controller.CreateFood())
 	incomingRoutes.PATCH("/foods/:food_id", controller.UpdateFood())
+	incomingRoutes.GET("/foods/:food_id/prices", controller.GetPriceHistory())
+	incomingRoutes.POST("/foods/:food_id/prices", controller.SchedulePriceChange())
+	incomingRoutes.POST("/foods/:food_id/prices/:price_change_id/cancel", controller.CancelPriceChange())
 }
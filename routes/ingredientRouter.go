@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func IngredientRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/ingredients", controller.GetIngredients())
+	incomingRoutes.GET("/ingredients/:ingredient_id", controller.GetIngredient())
+	incomingRoutes.POST("/ingredients", controller.CreateIngredient())
+	incomingRoutes.PATCH("/ingredients/:ingredient_id", controller.UpdateIngredient())
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InventoryRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/inventory/movements", controller.GetStockMovements())
+	incomingRoutes.GET("/inventory/variance", controller.GetVarianceReport())
+	incomingRoutes.GET("/inventory/reorder-suggestions", controller.GetReorderSuggestions())
+	incomingRoutes.POST("/inventory/receipts", controller.RecordReceipt())
+	incomingRoutes.POST("/inventory/counts", controller.RecordCount())
+	incomingRoutes.POST("/inventory/waste", controller.RecordWaste())
+}
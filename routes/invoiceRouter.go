@@ -11,4 +11,9 @@ func InvoiceRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/invoices/:invoice_id", controller.GetInvoice())
 	incomingRoutes.POST("/invoices", controller.CreateInvoice())
 	incomingRoutes.PATCH("/invoices/:invoice_id", controller.UpdateInvoice())
+	incomingRoutes.DELETE("/invoices/:invoice_id/service-charge", controller.WaiveServiceCharge())
+	incomingRoutes.POST("/invoices/:invoice_id/tips", controller.AddTip())
+	incomingRoutes.DELETE("/invoices/:invoice_id/tips/:tip_id", controller.RemoveTip())
+	incomingRoutes.GET("/invoices/:invoice_id/payments", controller.GetInvoicePayments())
+	incomingRoutes.POST("/invoices/:invoice_id/payments", controller.AddPayment())
 }
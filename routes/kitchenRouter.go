@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func KitchenRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/kitchen/tickets", controller.GetKitchenTickets())
+	incomingRoutes.GET("/kitchen/stations/:station/tickets", controller.GetKitchenTickets())
+	incomingRoutes.POST("/kitchen/stations/:station/tickets/:order_id/bump", controller.BumpKitchenTicket())
+	incomingRoutes.POST("/kitchen/orderItems/:order_item_id/bump", controller.BumpOrderItem())
+	incomingRoutes.POST("/kitchen/orderItems/:order_item_id/recall", controller.RecallOrderItem())
+}
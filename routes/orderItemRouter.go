@@ -8,8 +8,13 @@ import (
 
 func OrderItemRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/orderItems", controller.GetOrderItems())
-	incomingRoutes.GET("/orderItems/:orderItem_id", controller.GetOrderItem())
-	incomingRoutes.GET("/orderItems-order/:orderItem_id", controller.GetOrderItemsByOrder())
+	incomingRoutes.GET("/orderItems/:order_item_id", controller.GetOrderItem())
+	incomingRoutes.GET("/orderItems-order/:order_id", controller.GetOrderItemsByOrder())
 	incomingRoutes.POST("/orderItems", controller.CreateOrderItem())
-	incomingRoutes.PATCH("/orderItems/:orderItem_id", controller.UpdateOrderItem())
+	incomingRoutes.PATCH("/orderItems/:order_item_id", controller.UpdateOrderItem())
+	incomingRoutes.POST("/orderItems/:order_item_id/transitions", controller.TransitionOrderItem())
+	incomingRoutes.POST("/orderItems/:order_item_id/void", controller.VoidOrderItem())
+	incomingRoutes.POST("/orderItems/:order_item_id/comp", controller.CompOrderItem())
+	incomingRoutes.POST("/orderItems/:order_item_id/discount", controller.SetOrderItemDiscount())
+	incomingRoutes.DELETE("/orderItems/:order_item_id/discount", controller.RemoveOrderItemDiscount())
 }
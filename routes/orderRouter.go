@@ -9,6 +9,14 @@ import (
 func OrderRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/orders", controller.GetOrders())
 	incomingRoutes.GET("/orders/:order_id", controller.GetOrder())
+	incomingRoutes.GET("/orders/:order_id/nutrition", controller.GetOrderNutrition())
 	incomingRoutes.POST("/orders", controller.CreateOrder())
 	incomingRoutes.PATCH("/orders/:order_id", controller.UpdateOrder())
+	incomingRoutes.POST("/orders/:order_id/transitions", controller.TransitionOrder())
+	incomingRoutes.POST("/orders/:order_id/courses/:course/fire", controller.FireCourse())
+	incomingRoutes.POST("/orders/:order_id/courses/:course/hold", controller.HoldCourse())
+	incomingRoutes.POST("/orders/:order_id/discounts", controller.AddOrderDiscount())
+	incomingRoutes.DELETE("/orders/:order_id/discounts/:discount_id", controller.RemoveOrderDiscount())
+	incomingRoutes.POST("/orders/:order_id/promo", controller.RedeemPromoCode())
+	incomingRoutes.POST("/orders/:order_id/split", controller.SplitOrder())
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PricingRuleRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/pricingRules", controller.GetPricingRules())
+	incomingRoutes.GET("/pricingRules/:pricing_rule_id", controller.GetPricingRule())
+	incomingRoutes.POST("/pricingRules", controller.CreatePricingRule())
+	incomingRoutes.PATCH("/pricingRules/:pricing_rule_id", controller.UpdatePricingRule())
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PromoCodeRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/promoCodes", controller.GetPromoCodes())
+	incomingRoutes.GET("/promoCodes/:promo_code_id", controller.GetPromoCode())
+	incomingRoutes.POST("/promoCodes", controller.CreatePromoCode())
+	incomingRoutes.PATCH("/promoCodes/:promo_code_id", controller.UpdatePromoCode())
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PurchaseOrderRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/purchaseOrders", controller.GetPurchaseOrders())
+	incomingRoutes.GET("/purchaseOrders/:purchase_order_id", controller.GetPurchaseOrder())
+	incomingRoutes.POST("/purchaseOrders", controller.CreatePurchaseOrder())
+	incomingRoutes.PATCH("/purchaseOrders/:purchase_order_id", controller.UpdatePurchaseOrder())
+	incomingRoutes.POST("/purchaseOrders/:purchase_order_id/send", controller.SendPurchaseOrder())
+	incomingRoutes.POST("/purchaseOrders/:purchase_order_id/cancel", controller.CancelPurchaseOrder())
+	incomingRoutes.POST("/purchaseOrders/:purchase_order_id/receive", controller.ReceivePurchaseOrder())
+}
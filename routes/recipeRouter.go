@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RecipeRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/recipes", controller.GetRecipes())
+	incomingRoutes.GET("/recipes/:recipe_id", controller.GetRecipe())
+	incomingRoutes.POST("/recipes", controller.CreateRecipe())
+	incomingRoutes.PATCH("/recipes/:recipe_id", controller.UpdateRecipe())
+}
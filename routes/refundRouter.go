@@ -0,0 +1,13 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RefundRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/payments/:payment_id/refunds", controller.RefundPayment())
+	incomingRoutes.GET("/creditNotes", controller.GetCreditNotes())
+	incomingRoutes.GET("/creditNotes/:credit_note_id", controller.GetCreditNote())
+}
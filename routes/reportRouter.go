@@ -0,0 +1,13 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReportRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/reports/tips", controller.GetTipReport())
+	incomingRoutes.GET("/reports/adjustments", controller.GetAdjustmentReport())
+	incomingRoutes.GET("/reports/sales", controller.GetSalesReport())
+}
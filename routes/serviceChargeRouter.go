@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ServiceChargeRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/serviceChargeRules", controller.GetServiceChargeRules())
+	incomingRoutes.GET("/serviceChargeRules/:service_charge_rule_id", controller.GetServiceChargeRule())
+	incomingRoutes.POST("/serviceChargeRules", controller.CreateServiceChargeRule())
+	incomingRoutes.PATCH("/serviceChargeRules/:service_charge_rule_id", controller.UpdateServiceChargeRule())
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SupplierRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/suppliers", controller.GetSuppliers())
+	incomingRoutes.GET("/suppliers/:supplier_id", controller.GetSupplier())
+	incomingRoutes.POST("/suppliers", controller.CreateSupplier())
+	incomingRoutes.PATCH("/suppliers/:supplier_id", controller.UpdateSupplier())
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	controller "restaurant-management-backend/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func UploadRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/foods/:food_id/image", controller.UploadFoodImage())
+	incomingRoutes.POST("/users/:user_id/avatar", controller.UploadUserAvatar())
+}
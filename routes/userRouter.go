@@ -12,3 +12,8 @@ func UserRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.POST("/users/signup", controller.SignUp())
 	incomingRoutes.POST("/users/login", controller.Login())
 }
+
+// UserAdminRoutes are the user routes that need a signed in user.
+func UserAdminRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.PATCH("/users/:user_id/role", controller.UpdateUserRole())
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// BlobStore stores uploaded files under a key and returns the URL they are served from.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (url string, err error)
+	Delete(ctx context.Context, key string) error
+}
+
+var UPLOAD_DIR string = uploadDir()
+
+// UPLOAD_URL is the URL prefix the upload directory is served under.
+const UPLOAD_URL = "/uploads"
+
+func uploadDir() string {
+	dir := os.Getenv("UPLOAD_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	return dir
+}
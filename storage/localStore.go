@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore is a BlobStore that writes files to a directory on the local filesystem.
+type LocalStore struct {
+	Dir     string
+	BaseURL string
+}
+
+func NewLocalStore(dir string, baseURL string) *LocalStore {
+	return &LocalStore{Dir: dir, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	filePath, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	// write to a temporary file first so readers never see a partial file
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return "", err
+	}
+
+	return s.BaseURL + "/" + path.Clean(key), nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid key: %s", key)
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(cleaned)), nil
+}